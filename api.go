@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// triggerStatus is result of trigger request for single container of the service.
+type triggerStatus struct {
+	Container string `json:"container"`
+	Status    string `json:"status"` // started, queued, already_running or failed
+	RunID     string `json:"run_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+const (
+	triggerStarted        = "started"
+	triggerQueued         = "queued"
+	triggerAlreadyRunning = "already_running"
+	triggerFailed         = "failed"
+)
+
+// api is admin HTTP API to inspect and trigger jobs at runtime. Triggered runs are tracked to be awaited on shutdown.
+type api struct {
+	ctx   context.Context
+	sc    *Scheduler
+	token string
+	runs  sync.WaitGroup
+}
+
+func (a *api) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/version", a.sc.serveVersion)
+	mux.HandleFunc("/jobs", a.listJobs)
+	mux.HandleFunc("/jobs/", a.triggerJob)
+	return mux
+}
+
+// listJobs handles GET /jobs.
+func (a *api) listJobs(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(writer, http.StatusOK, a.sc.Status())
+}
+
+// triggerJob handles POST /jobs/{service}/trigger?job={name}&project={project}. Default job is used if name is not
+// set. Project is required only if service with the same name exists in several projects. Run is started in
+// background according to concurrency policy of the job, for every container of the service, once its container is
+// located, so missing containers and Docker errors are reported in response.
+func (a *api) triggerJob(writer http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/jobs/")
+	service := strings.TrimSuffix(path, "/trigger")
+	if service == path || service == "" || strings.Contains(service, "/") {
+		http.NotFound(writer, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(req) {
+		http.Error(writer, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := req.URL.Query().Get("job")
+	project := req.URL.Query().Get("project")
+	if name == "" {
+		name = defaultJobName
+	}
+
+	sc := a.sc
+	sc.lock.Lock()
+	var jobs []*job
+	var tasks []Task
+	for _, j := range sc.jobs {
+		if t := j.current(); t.Service == service && t.Name == name && (project == "" || t.Project == project) {
+			jobs = append(jobs, j)
+			tasks = append(tasks, t)
+		}
+	}
+	sc.lock.Unlock()
+	if len(jobs) == 0 {
+		http.Error(writer, "job not found", http.StatusNotFound)
+		return
+	}
+	if err := checkProjects(service, tasks); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var accepted, failed bool
+	var list = make([]triggerStatus, 0, len(jobs))
+	for _, j := range jobs {
+		status := triggerStatus{Container: j.current().Container}
+		tk, err := j.reserve()
+		if errors.Is(err, errTaskRunning) {
+			status.Status = triggerAlreadyRunning
+			status.Error = err.Error()
+			list = append(list, status)
+			continue
+		}
+		if err := sc.locateContainer(req.Context(), j); err != nil {
+			tk.release()
+			status.Status = triggerFailed
+			status.Error = err.Error()
+			failed = true
+			list = append(list, status)
+			continue
+		}
+		r := &run{id: newRunID(), ticket: tk}
+		status.Container = j.current().Container
+		status.RunID = r.id
+		status.Status = triggerStarted
+		if tk.queued {
+			status.Status = triggerQueued
+		}
+		accepted = true
+		a.runs.Add(1)
+		go func(j *job) {
+			defer a.runs.Done()
+			_ = sc.runJob(a.ctx, j, r)
+		}(j)
+		list = append(list, status)
+	}
+	code := http.StatusConflict
+	switch {
+	case failed:
+		code = http.StatusInternalServerError
+	case accepted:
+		code = http.StatusAccepted
+	}
+	writeJSON(writer, code, list)
+}
+
+// authorized checks bearer token if it is configured.
+func (a *api) authorized(req *http.Request) bool {
+	if a.token == "" {
+		return true
+	}
+	header := req.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	return token != header && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+func writeJSON(writer http.ResponseWriter, code int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(value)
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTriggerReportsMissingContainer(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	sc := newTestScheduler(t, fd)
+	a := newTestAPI(t, sc)
+
+	fd.lock.Lock()
+	fd.containers = nil // removed after discovery without replacement
+	fd.lock.Unlock()
+
+	code, list := postTrigger(t, a, "/jobs/web/trigger")
+	if code != http.StatusInternalServerError {
+		t.Fatalf("expected code %d, got %d", http.StatusInternalServerError, code)
+	}
+	if len(list) != 1 || list[0].Status != triggerFailed || !strings.Contains(list[0].Error, errContainerMissing.Error()) {
+		t.Fatalf("expected failed status with missing container, got %+v", list)
+	}
+	if e := fd.lastExec(); e != nil {
+		t.Fatalf("command must not be executed, got exec %s", e.ID)
+	}
+}
+
+func TestTriggerStartsRun(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	sc := newTestScheduler(t, fd)
+	a := newTestAPI(t, sc)
+
+	code, list := postTrigger(t, a, "/jobs/web/trigger")
+	a.runs.Wait()
+	if code != http.StatusAccepted {
+		t.Fatalf("expected code %d, got %d", http.StatusAccepted, code)
+	}
+	if len(list) != 1 || list[0].Status != triggerStarted || list[0].RunID == "" {
+		t.Fatalf("expected started run, got %+v", list)
+	}
+	if e := fd.lastExec(); e == nil || e.Container != "c1" {
+		t.Fatalf("expected exec in c1, got %+v", e)
+	}
+}
+
+// newTestAPI creates API with jobs of discovered tasks, without scheduling them.
+func newTestAPI(t *testing.T, sc *Scheduler) *api {
+	t.Helper()
+	tasks, err := sc.Tasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.jobs = make(map[string]*job)
+	for _, task := range tasks {
+		sc.jobs[task.key()] = newJob(task)
+	}
+	return &api{ctx: context.Background(), sc: sc}
+}
+
+func postTrigger(t *testing.T, a *api, target string) (int, []triggerStatus) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	a.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+	var list []triggerStatus
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, list
+}
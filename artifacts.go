@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultArtifactsDir = "/artifacts"
+	artifactTimeFormat  = "20060102T150405.000Z"
+	artifactExt         = ".log"
+)
+
+// artifactStore keeps output of each run as <dir>/<project>/<service>/<timestamp>.log for default job
+// and <dir>/<project>/<service>/<job>/<timestamp>.log for named jobs.
+type artifactStore struct {
+	dir       string
+	retention time.Duration // zero means keep forever
+}
+
+func (as *artifactStore) root() string {
+	if as.dir == "" {
+		return defaultArtifactsDir
+	}
+	return as.dir
+}
+
+func (as *artifactStore) path(task Task) string {
+	if task.Name == defaultJobName {
+		return filepath.Join(as.root(), task.Project, task.Service)
+	}
+	return filepath.Join(as.root(), task.Project, task.Service, task.Name)
+}
+
+func (as *artifactStore) create(task Task, at time.Time) (*os.File, error) {
+	dir := as.path(task)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create artifacts dir: %w", err)
+	}
+	return os.Create(filepath.Join(dir, at.UTC().Format(artifactTimeFormat)+artifactExt))
+}
+
+// prune removes artifacts of the job older than retention.
+func (as *artifactStore) prune(task Task, now time.Time) error {
+	if as.retention <= 0 {
+		return nil
+	}
+	dir := as.path(task)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read artifacts dir: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), artifactExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) <= as.retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return fmt.Errorf("remove artifact: %w", err)
+		}
+	}
+	return nil
+}
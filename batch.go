@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batcher accumulates notification payloads to deliver them as a single batch.
+type batcher struct {
+	interval       time.Duration
+	size           int  // zero means no limit
+	bypassFailures bool // send failures immediately
+
+	lock    sync.Mutex
+	pending []*Payload
+	full    chan struct{}
+}
+
+func newBatcher(interval time.Duration, size int, bypassFailures bool) *batcher {
+	return &batcher{
+		interval:       interval,
+		size:           size,
+		bypassFailures: bypassFailures,
+		full:           make(chan struct{}, 1),
+	}
+}
+
+func (b *batcher) add(payload *Payload) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.pending = append(b.pending, payload)
+	if b.size > 0 && len(b.pending) >= b.size {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batcher) take() []*Payload {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	list := b.pending
+	b.pending = nil
+	return list
+}
+
+// runBatcher delivers accumulated payloads every interval or once batch is full. Pending batch is left on exit for
+// the bounded flush on shutdown, see flushNotifications.
+func (sc *Scheduler) runBatcher(ctx context.Context) {
+	ticker := time.NewTicker(sc.batch.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-sc.batch.full:
+		case <-ctx.Done():
+			return
+		}
+		sc.flushBatch(ctx)
+	}
+}
+
+func (sc *Scheduler) flushBatch(ctx context.Context) {
+	list := sc.batch.take()
+	if len(list) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, notifier := range sc.notifiers {
+		batchNotifier, ok := notifier.(BatchNotifier)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger := sc.logger.With("notifier", notifierName(batchNotifier), "records", len(list))
+			if err := batchNotifier.NotifyBatch(withLogger(ctx, logger), list); err != nil {
+				logger.Error("batch notification failed", "error", err)
+			} else {
+				logger.Info("batch notification delivered")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,15 @@
+package scheduler
+
+import "time"
+
+// Clock is source of current time used for run timestamps, catch-up and artifacts, so it can be controlled in tests.
+// Cron engine fires jobs by its own timer.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
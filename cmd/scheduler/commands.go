@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	scheduler "github.com/reddec/compose-scheduler"
+)
+
+const shortIDLength = 12
+
+type TriggerCommand struct {
+	All     bool   `long:"all" description:"Run on every container if service is scaled"`
+	Project string `long:"project" description:"Project of the service, required if service with the same name exists in several projects"`
+	Args    struct {
+		Service string `positional-arg-name:"service" required:"yes" description:"Service name"`
+		Job     string `positional-arg-name:"job" description:"Job name, default job if not set"`
+	} `positional-args:"yes"`
+}
+
+func runCommand(ctx context.Context, sc *scheduler.Scheduler, config *Config, name string) error {
+	switch name {
+	case "list":
+		return listJobs(ctx, sc)
+	case "trigger":
+		return sc.Trigger(ctx, config.Trigger.Project, config.Trigger.Args.Service, config.Trigger.Args.Job, config.Trigger.All)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// listJobs prints discovered jobs as table. Fails if any job can not be scheduled.
+func listJobs(ctx context.Context, sc *scheduler.Scheduler) error {
+	tasks, err := sc.Tasks(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "SERVICE\tJOB\tCONTAINER\tSCHEDULE\tMODE\tLOGGING\tNEXT")
+	for _, t := range tasks {
+		next, err := sc.NextRun(t, now)
+		if err != nil {
+			return err
+		}
+		nextRun := "-" // @startup jobs are not scheduled
+		if !next.IsZero() {
+			nextRun = next.Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n", t.Service, t.Name, shortID(t.Container), t.Schedule, t.Mode, t.Logging, nextRun)
+	}
+	return out.Flush()
+}
+
+// checkJobs validates discovered jobs and reports their number.
+func checkJobs(ctx context.Context, sc *scheduler.Scheduler) error {
+	tasks, err := sc.Tasks(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d jobs are valid\n", len(tasks))
+	return nil
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+// exitCode of the process: exit code of the job if available.
+func exitCode(err error) int {
+	var exitErr *scheduler.ExitError
+	if errors.As(err, &exitErr) && exitErr.Code > 0 {
+		return exitErr.Code
+	}
+	return 1
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/jessevdk/go-flags"
 	scheduler "github.com/reddec/compose-scheduler"
@@ -20,8 +23,69 @@ var (
 )
 
 type Config struct {
-	Project string                     `long:"project" env:"PROJECT" description:"Docker compose project, will be automatically detected if not set"`
-	Notify  scheduler.HTTPNotification `group:"HTTP notification" namespace:"notify" env-namespace:"NOTIFY"`
+	Project      []string                   `long:"project" env:"PROJECT" env-delim:"," description:"Docker compose project, can be repeated to schedule jobs of several projects, will be automatically detected if not set"`
+	Hostname     string                     `long:"hostname" env:"HOSTNAME" description:"Name of the host in notifications and for spreading jobs, system hostname if not set"`
+	ProjectLabel string                     `long:"project-label" env:"PROJECT_LABEL" description:"Label identifying project of containers" default:"com.docker.compose.project"`
+	ServiceLabel string                     `long:"service-label" env:"SERVICE_LABEL" description:"Label identifying service of containers" default:"com.docker.compose.service"`
+	Notify       scheduler.HTTPNotification `group:"HTTP notification" namespace:"notify" env-namespace:"NOTIFY"`
+
+	ArtifactDir       string        `long:"artifact-dir" env:"ARTIFACT_DIR" description:"Directory for jobs output with artifacts label" default:"/artifacts"`
+	ArtifactRetention time.Duration `long:"artifact-retention" env:"ARTIFACT_RETENTION" description:"Remove artifacts older than this duration, 0 means keep forever"`
+
+	DockerConnectRetries  int           `long:"docker-connect-retries" env:"DOCKER_CONNECT_RETRIES" description:"Number of additional attempts to reach Docker daemon at startup" default:"10"`
+	DockerConnectInterval time.Duration `long:"docker-connect-interval" env:"DOCKER_CONNECT_INTERVAL" description:"Interval between attempts to reach Docker daemon" default:"3s"`
+	DockerHost            string        `long:"docker-host" env:"DOCKER_HOST" description:"Docker daemon address (ex: tcp://10.0.0.2:2376), local socket if not set"`
+	DockerCertPath        string        `long:"docker-cert-path" env:"DOCKER_CERT_PATH" description:"Directory with ca.pem, cert.pem and key.pem for TLS connection to Docker daemon"`
+	DockerTLSVerify       bool          `long:"docker-tls-verify" env:"DOCKER_TLS_VERIFY" description:"Verify Docker daemon certificate, requires cert path"`
+	DockerAPIVersion      string        `long:"docker-api-version" env:"DOCKER_API_VERSION" description:"Pin Docker API version, negotiated with daemon if not set"`
+	SpreadBy              string        `long:"spread-by" env:"SPREAD_BY" description:"Spread fire time of jobs by deterministic offset" choice:"host"`
+	Seconds               bool          `long:"seconds" env:"SECONDS" description:"Parse all schedules as six-field expressions with seconds"`
+	Timezone              string        `long:"timezone" env:"TZ" description:"Default timezone for schedules (ex: Europe/Berlin), local time if not set"`
+	Jitter                time.Duration `long:"jitter" env:"JITTER" description:"Delay every scheduled run by random duration up to this value, can be overridden by label"`
+	StopGrace             time.Duration `long:"stop-grace" env:"STOP_GRACE" description:"Wait for service container to stop after timeout or shutdown before killing it" default:"10s"`
+	StateFile             string        `long:"state-file" env:"STATE_FILE" description:"File to keep last run time of jobs between restarts, required for catch-up"`
+	HistoryFile           string        `long:"history-file" env:"HISTORY_FILE" description:"Append result of every run as JSON line to the file, disabled if not set"`
+	HistoryMax            int           `long:"history-max" env:"HISTORY_MAX" description:"Keep at most this number of records in history file, 0 means no limit" default:"10000"`
+	MaxConcurrent         int           `long:"max-concurrent" env:"MAX_CONCURRENT" description:"Maximum number of jobs running at the same time, 0 means no limit"`
+	Crontab               string        `long:"crontab" env:"CRONTAB" description:"Crontab-like file with additional jobs: <schedule> <service> [command...]"`
+	MetricsAddr           string        `long:"metrics-addr" env:"METRICS_ADDR" description:"Address to serve Prometheus metrics on /metrics, disabled if not set"`
+	RunOnce               bool          `long:"run-once" env:"RUN_ONCE" description:"Run every job once in parallel and exit, non-zero exit code if any job failed"`
+	APIAddr               string        `long:"api-addr" env:"API_ADDR" description:"Address to serve admin HTTP API, disabled if not set"`
+	APIToken              string        `long:"api-token" env:"API_TOKEN" description:"Bearer token required to trigger jobs via API"`
+	HealthAddr            string        `long:"health-addr" env:"HEALTH_ADDR" description:"Address to serve health endpoint /healthz, disabled if not set"`
+	Watch                 bool          `long:"watch" env:"WATCH" description:"Watch Docker events and reschedule jobs when services are redeployed"`
+	DryRun                bool          `long:"dry-run" env:"DRY_RUN" description:"Log what would be executed instead of running jobs, notifications are still sent"`
+	Check                 bool          `long:"check" env:"CHECK" description:"Validate jobs and schedules, then exit"`
+	ForwardEnvPrefix      string        `long:"forward-env-prefix" env:"FORWARD_ENV_PREFIX" description:"Pass scheduler environment variables with this prefix to exec commands"`
+	MinInterval           time.Duration `long:"min-interval" env:"MIN_INTERVAL" description:"Reject jobs firing more often than this interval, 0 means no limit"`
+	OnParseError          string        `long:"on-parse-error" env:"ON_PARSE_ERROR" description:"What to do with invalid jobs: fail to start or skip them" default:"fail" choice:"fail" choice:"skip"`
+	LogFormat             string        `long:"log-format" env:"LOG_FORMAT" description:"Format of logs" default:"text" choice:"text" choice:"json"`
+
+	NotifyBatchInterval       time.Duration `long:"notify-batch-interval" env:"NOTIFY_BATCH_INTERVAL" description:"Send notifications in batches with this interval, disabled if not set"`
+	NotifyBatchSize           int           `long:"notify-batch-size" env:"NOTIFY_BATCH_SIZE" description:"Send batch earlier once it reaches this size, 0 means no limit" default:"100"`
+	NotifyBatchBypassFailures bool          `long:"notify-batch-bypass-failures" env:"NOTIFY_BATCH_BYPASS_FAILURES" description:"Send notifications about failed jobs immediately"`
+	NotifyOn                  string        `long:"notify-on" env:"NOTIFY_ON" description:"Which runs to notify about, can be overridden by label" default:"always" choice:"always" choice:"failure" choice:"change"`
+	LogDir                    string        `long:"log-dir" env:"LOG_DIR" description:"Write output of jobs to <dir>/<project>-<service>-<job>.log instead of scheduler logs"`
+	LogMaxSize                int64         `long:"log-max-size" env:"LOG_MAX_SIZE" description:"Rotate job log file once it exceeds this size in bytes, 0 means no rotation" default:"10485760"`
+	MaxLogBytes               int           `long:"max-log-bytes" env:"MAX_LOG_BYTES" description:"Max size in bytes of job output copied to scheduler logs (only for jobs with logs), 0 means no limit" default:"1048576"`
+	NotifyQueueSize           int           `long:"notify-queue-size" env:"NOTIFY_QUEUE_SIZE" description:"Max pending notifications delivered in background, jobs wait if queue is full, 0 means jobs deliver notifications themselves" default:"100"`
+	NotifyLifecycle           bool          `long:"notify-lifecycle" env:"NOTIFY_LIFECYCLE" description:"Notify when scheduler starts and stops"`
+	NotifySkipped             bool          `long:"notify-skipped" env:"NOTIFY_SKIPPED" description:"Notify about runs dropped because previous run of the job is still in progress or container is not healthy"`
+	NotifyMaxOutput           int           `long:"notify-max-output" env:"NOTIFY_MAX_OUTPUT" description:"Max size in bytes of command output tail in notifications (only for jobs with logs)" default:"8192"`
+	NotifyOutputEncoding      string        `long:"notify-output-encoding" env:"NOTIFY_OUTPUT_ENCODING" description:"How to put non-UTF8 output into notifications" default:"sanitize" choice:"sanitize" choice:"base64" choice:"drop"`
+
+	SlackURL string `long:"slack-url" env:"SLACK_URL" description:"Slack incoming webhook URL for notifications, uses retry settings and timeout of HTTP notification"`
+
+	RedisURL     string        `long:"redis-url" env:"REDIS_URL" description:"Publish notifications to Redis: redis://[[user]:password@]host[:port] or rediss:// for TLS"`
+	RedisChannel string        `long:"redis-channel" env:"REDIS_CHANNEL" description:"Redis channel for notifications" default:"scheduler"`
+	RedisTimeout time.Duration `long:"redis-timeout" env:"REDIS_TIMEOUT" description:"Redis publish timeout" default:"10s"`
+
+	LeaderCheckURL string        `long:"leader-check-url" env:"LEADER_CHECK_URL" description:"URL to check leadership for leader-only jobs, 2xx means leader"`
+	LeaderFile     string        `long:"leader-file" env:"LEADER_FILE" description:"File with leadership status (true/false) for leader-only jobs"`
+	LeaderCache    time.Duration `long:"leader-cache" env:"LEADER_CACHE" description:"How long to cache leadership status" default:"5s"`
+
+	List    struct{}       `command:"list" description:"List jobs discovered from labels and crontab without running them"`
+	Trigger TriggerCommand `command:"trigger" description:"Run job once now and exit with its exit code"`
 }
 
 func main() {
@@ -31,29 +95,208 @@ func main() {
 	parser.ShortDescription = "Compose scheduler"
 	parser.LongDescription = fmt.Sprintf("Docker compose scheduler\nscheduler %s, commit %s, built at %s by %s\nAuthor: Aleksandr Baryshnikov <owner@reddec.net>", version, commit, date, builtBy)
 
+	parser.SubcommandsOptional = true
+
 	if _, err := parser.Parse(); err != nil {
 		os.Exit(1)
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	logger := newLogger(config.LogFormat)
+	slog.SetDefault(logger)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	var opts []scheduler.Option
-	if config.Project != "" {
-		opts = append(opts, scheduler.WithProject(config.Project))
-	}
-	if config.Notify.URL != "" {
-		opts = append(opts, scheduler.WithNotification(&config.Notify))
-	}
-	sc, err := scheduler.Create(ctx, opts...)
+	build := scheduler.BuildInfo{Version: version, Commit: commit, Date: date, BuiltBy: builtBy}
+	sc, err := scheduler.Create(ctx, append(config.options(), scheduler.WithLogger(logger), scheduler.WithBuildInfo(build))...)
 	if err != nil {
-		log.Panic(err)
+		logger.Error("create scheduler failed", "error", err)
+		os.Exit(1)
 	}
 	defer sc.Close()
-	log.Println("started")
+
+	if config.Check {
+		if err := checkJobs(ctx, sc); err != nil {
+			logger.Error("check failed", "error", err)
+			sc.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parser.Active != nil {
+		if err := runCommand(ctx, sc, &config, parser.Active.Name); err != nil {
+			logger.Error("command failed", "command", parser.Active.Name, "error", err)
+			sc.Close()
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	go pauseBySignals(ctx, sc)
+	go reloadBySignal(ctx, sc)
+
+	logger.Info("started", "version", version, "commit", commit, "date", date, "built_by", builtBy)
 	err = sc.Run(ctx)
 	if err != nil {
-		log.Panic(err)
+		logger.Error("scheduler failed", "error", err)
+		sc.Close()
+		os.Exit(1)
+	}
+	logger.Info("finished")
+}
+
+// pauseBySignals pauses scheduling on SIGUSR1 and resumes it on SIGUSR2.
+func pauseBySignals(ctx context.Context, sc *scheduler.Scheduler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGUSR1 {
+				sc.Pause()
+			} else {
+				sc.Resume()
+			}
+		}
+	}
+}
+
+// reloadBySignal re-discovers jobs on SIGHUP.
+func reloadBySignal(ctx context.Context, sc *scheduler.Scheduler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			sc.Reload()
+		}
+	}
+}
+
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+func (cfg *Config) options() []scheduler.Option {
+	var opts = []scheduler.Option{
+		scheduler.WithArtifacts(cfg.ArtifactDir, cfg.ArtifactRetention),
+		scheduler.WithConnectRetry(cfg.DockerConnectRetries, cfg.DockerConnectInterval),
+		scheduler.WithStopGrace(cfg.StopGrace),
+		scheduler.WithMaxConcurrent(cfg.MaxConcurrent),
+		scheduler.WithMaxLogBytes(cfg.MaxLogBytes),
+		scheduler.WithProjectLabel(cfg.ProjectLabel),
+		scheduler.WithServiceLabel(cfg.ServiceLabel),
+		scheduler.WithNotificationDefaults(&cfg.Notify),
+		scheduler.WithOnParseError(scheduler.ParseErrorAction(cfg.OnParseError)),
+	}
+	for _, project := range cfg.Project {
+		opts = append(opts, scheduler.WithProject(project))
+	}
+	if cfg.Hostname != "" {
+		opts = append(opts, scheduler.WithHostname(cfg.Hostname))
+	}
+	if cfg.DockerHost != "" {
+		opts = append(opts, scheduler.WithDockerHost(cfg.DockerHost))
+	}
+	if cfg.DockerCertPath != "" {
+		opts = append(opts, scheduler.WithDockerTLS(cfg.DockerCertPath, cfg.DockerTLSVerify))
+	}
+	if cfg.DockerAPIVersion != "" {
+		opts = append(opts, scheduler.WithAPIVersion(cfg.DockerAPIVersion))
+	}
+	if cfg.SpreadBy == "host" {
+		opts = append(opts, scheduler.WithSpreadByHost())
+	}
+	if cfg.DryRun {
+		opts = append(opts, scheduler.WithDryRun())
+	}
+	if cfg.RunOnce {
+		opts = append(opts, scheduler.WithRunOnce())
+	}
+	if cfg.Seconds {
+		opts = append(opts, scheduler.WithSeconds())
+	}
+	if cfg.Timezone != "" {
+		opts = append(opts, scheduler.WithTimezone(cfg.Timezone))
+	}
+	if cfg.ForwardEnvPrefix != "" {
+		opts = append(opts, scheduler.WithForwardEnv(cfg.ForwardEnvPrefix))
+	}
+	if cfg.MinInterval > 0 {
+		opts = append(opts, scheduler.WithMinInterval(cfg.MinInterval))
+	}
+	if cfg.Jitter > 0 {
+		opts = append(opts, scheduler.WithJitter(cfg.Jitter))
+	}
+	if cfg.StateFile != "" {
+		opts = append(opts, scheduler.WithStateFile(cfg.StateFile))
+	}
+	if cfg.LogDir != "" {
+		opts = append(opts, scheduler.WithLogDir(cfg.LogDir, cfg.LogMaxSize))
+	}
+	if cfg.HistoryFile != "" {
+		opts = append(opts, scheduler.WithHistory(cfg.HistoryFile, cfg.HistoryMax))
+	}
+	if cfg.Crontab != "" {
+		opts = append(opts, scheduler.WithCrontab(cfg.Crontab))
+	}
+	if cfg.MetricsAddr != "" {
+		opts = append(opts, scheduler.WithMetrics(cfg.MetricsAddr))
+	}
+	if cfg.APIAddr != "" {
+		opts = append(opts, scheduler.WithAPI(cfg.APIAddr, cfg.APIToken))
+	}
+	if cfg.HealthAddr != "" {
+		opts = append(opts, scheduler.WithHealth(cfg.HealthAddr))
+	}
+	if cfg.Watch {
+		opts = append(opts, scheduler.WithWatch())
+	}
+	if cfg.Notify.URL != "" {
+		opts = append(opts, scheduler.WithNotification(&cfg.Notify))
+	}
+	if cfg.LeaderCheckURL != "" || cfg.LeaderFile != "" {
+		opts = append(opts, scheduler.WithLeaderCheck(cfg.LeaderCheckURL, cfg.LeaderFile, cfg.LeaderCache))
+	}
+	if cfg.NotifyLifecycle {
+		opts = append(opts, scheduler.WithNotifyLifecycle())
+	}
+	if cfg.NotifySkipped {
+		opts = append(opts, scheduler.WithNotifySkipped())
+	}
+	opts = append(opts, scheduler.WithNotifyOn(scheduler.NotifyOn(cfg.NotifyOn)))
+	opts = append(opts, scheduler.WithNotifyQueue(cfg.NotifyQueueSize))
+	opts = append(opts, scheduler.WithOutputEncoding(scheduler.OutputEncoding(cfg.NotifyOutputEncoding)), scheduler.WithMaxOutput(cfg.NotifyMaxOutput))
+	if cfg.SlackURL != "" {
+		opts = append(opts, scheduler.WithNotifier(&scheduler.SlackNotification{
+			URL:           cfg.SlackURL,
+			Retries:       cfg.Notify.Retries,
+			Interval:      cfg.Notify.Interval,
+			BackoffFactor: cfg.Notify.BackoffFactor,
+			MaxInterval:   cfg.Notify.MaxInterval,
+			Jitter:        cfg.Notify.Jitter,
+			Timeout:       cfg.Notify.Timeout,
+		}))
+	}
+	if cfg.RedisURL != "" {
+		opts = append(opts, scheduler.WithRedis(&scheduler.RedisNotification{
+			URL:     cfg.RedisURL,
+			Channel: cfg.RedisChannel,
+			Timeout: cfg.RedisTimeout,
+		}))
+	}
+	if cfg.NotifyBatchInterval > 0 {
+		opts = append(opts, scheduler.WithNotificationBatch(cfg.NotifyBatchInterval, cfg.NotifyBatchSize, cfg.NotifyBatchBypassFailures))
 	}
-	log.Println("finished")
+	return opts
 }
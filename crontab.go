@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/robfig/cron/v3"
+)
+
+// crontabEntry is single line of crontab file: <schedule> <service> [command...].
+type crontabEntry struct {
+	line     int
+	schedule string
+	service  string
+	command  []string
+}
+
+func (ce crontabEntry) name() string {
+	return "crontab-" + strconv.Itoa(ce.line)
+}
+
+func readCrontab(file string, parser cron.ScheduleParser, fields int) ([]crontabEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCrontab(f, parser, fields)
+}
+
+// parseCrontab parses crontab-like content. Schedule is either a descriptor (@daily, @every 5m) or the given number of
+// fields, optionally prefixed by time zone (CRON_TZ=Europe/Berlin). Empty lines and lines started with # are ignored.
+func parseCrontab(r io.Reader, parser cron.ScheduleParser, fields int) ([]crontabEntry, error) {
+	var ans []crontabEntry
+	scanner := bufio.NewScanner(r)
+	var num int
+	for scanner.Scan() {
+		num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n := scheduleFields(line, fields)
+		parts, rest := cutFields(line, n+1)
+		if len(parts) != n+1 {
+			return nil, fmt.Errorf("line %d: expected schedule and service", num)
+		}
+		schedule := strings.Join(parts[:n], " ")
+		if _, err := parser.Parse(schedule); err != nil {
+			return nil, fmt.Errorf("line %d: parse schedule: %w", num, err)
+		}
+		command, err := shellquote.Split(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse command: %w", num, err)
+		}
+		ans = append(ans, crontabEntry{
+			line:     num,
+			schedule: schedule,
+			service:  parts[n],
+			command:  command,
+		})
+	}
+	return ans, scanner.Err()
+}
+
+// scheduleFields returns number of leading fields of the line forming schedule: optional time zone followed by
+// descriptor (@every takes interval as the next field) or the given number of fields.
+func scheduleFields(line string, fields int) int {
+	tokens, _ := cutFields(line, 2)
+	var n int
+	if len(tokens) > 0 && (strings.HasPrefix(tokens[0], "CRON_TZ=") || strings.HasPrefix(tokens[0], "TZ=")) {
+		n++
+		tokens = tokens[1:]
+	}
+	switch {
+	case len(tokens) == 0:
+		return n + fields
+	case tokens[0] == "@every":
+		return n + 2
+	case strings.HasPrefix(tokens[0], "@"):
+		return n + 1
+	default:
+		return n + fields
+	}
+}
+
+// cutFields returns up to n whitespace-separated fields and the rest of the string as-is.
+func cutFields(s string, n int) ([]string, string) {
+	var ans []string
+	for len(ans) < n {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		ans = append(ans, s[:end])
+		s = s[end:]
+	}
+	return ans, strings.TrimSpace(s)
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCrontab(t *testing.T) {
+	cases := []struct {
+		name    string
+		line    string
+		entry   crontabEntry
+		invalid bool
+	}{
+		{name: "fields", line: "*/5 * * * * db", entry: crontabEntry{schedule: "*/5 * * * *", service: "db", command: []string{}}},
+		{name: "descriptor", line: "@daily   db   backup.sh --full", entry: crontabEntry{schedule: "@daily", service: "db", command: []string{"backup.sh", "--full"}}},
+		{name: "every", line: "@every 5m db backup.sh", entry: crontabEntry{schedule: "@every 5m", service: "db", command: []string{"backup.sh"}}},
+		{name: "time zone", line: "CRON_TZ=Europe/Berlin 0 2 * * * db", entry: crontabEntry{schedule: "CRON_TZ=Europe/Berlin 0 2 * * *", service: "db", command: []string{}}},
+		{name: "short time zone with descriptor", line: "TZ=UTC @hourly web curl 'http://localhost/ping'", entry: crontabEntry{schedule: "TZ=UTC @hourly", service: "web", command: []string{"curl", "http://localhost/ping"}}},
+		{name: "missing service", line: "0 2 * * *", invalid: true},
+		{name: "missing service after every", line: "@every 5m", invalid: true},
+		{name: "bad schedule", line: "0 25 * * * db", invalid: true},
+		{name: "bad quote", line: "@daily db echo 'unterminated", invalid: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			content := "# comment\n\n  # indented comment\n" + c.line + "\n"
+			list, err := parseCrontab(strings.NewReader(content), standardParser, standardFields)
+			if c.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", list)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.entry.line = 4 // after comments and empty line
+			if len(list) != 1 || !reflect.DeepEqual(list[0], c.entry) {
+				t.Fatalf("expected %+v, got %+v", c.entry, list)
+			}
+		})
+	}
+}
+
+func TestCrontabSkipsOneoffContainers(t *testing.T) {
+	oneoff := testContainer("c2", "db")
+	oneoff.Name = "test-db-run-1a2b3c"
+	oneoff.Labels[composeOneoffLabel] = "True"
+	fd := newFakeDocker(t, testContainer("c1", "db"), oneoff)
+	file := filepath.Join(t.TempDir(), "crontab")
+	if err := os.WriteFile(file, []byte("@daily db backup.sh\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc := newTestScheduler(t, fd, WithCrontab(file))
+
+	tasks, err := sc.Tasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Container != "c1" {
+		t.Fatalf("expected single task in c1, got %+v", tasks)
+	}
+}
@@ -0,0 +1,438 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+const dockerModule = "github.com/docker/docker"
+
+// dockerAPI is thin adapter over Docker SDK. SDK request/response types must be used only here, so
+// differences between SDK and daemon API versions are isolated in one place.
+type dockerAPI struct {
+	lock   sync.RWMutex
+	client *client.Client
+}
+
+// WithDocker uses provided client instead of creating one. The client is not closed or replaced by scheduler. It's
+// defined here, as the only option exposing SDK type.
+func WithDocker(dockerClient *client.Client) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.docker = &dockerAPI{client: dockerClient}
+		scheduler.borrowed = true
+	}
+}
+
+func (d *dockerAPI) cli() *client.Client {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.client
+}
+
+// newDockerAPI creates client configured by environment (DOCKER_HOST and others) and explicit settings. API version
+// is negotiated with daemon unless set.
+func newDockerAPI(host, certPath string, verifyTLS bool, apiVersion string) (*dockerAPI, error) {
+	opts := []client.Opt{client.FromEnv}
+	if certPath != "" {
+		opts = append(opts, withTLS(certPath, verifyTLS))
+	}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	if apiVersion != "" {
+		opts = append(opts, client.WithVersion(apiVersion))
+	} else {
+		opts = append(opts, client.WithAPIVersionNegotiation())
+	}
+	c, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerAPI{client: c}, nil
+}
+
+func (d *dockerAPI) close() error {
+	return d.cli().Close()
+}
+
+// replace client by client of the fresh adapter, ex. after daemon restart, and close the previous client.
+func (d *dockerAPI) replace(fresh *dockerAPI) error {
+	d.lock.Lock()
+	old := d.client
+	d.client = fresh.cli()
+	d.lock.Unlock()
+	return old.Close()
+}
+
+type execSpec struct {
+	Cmd        []string
+	Env        []string
+	User       string
+	WorkingDir string
+	Attach     bool
+	Stdin      bool
+	Privileged bool
+	Tty        bool
+}
+
+// execStream is connection to attached exec: input of the command and its demultiplexed output.
+type execStream struct {
+	conn types.HijackedResponse
+	tty  bool
+}
+
+// Write sends input to the command.
+func (s *execStream) Write(data []byte) (int, error) {
+	return s.conn.Conn.Write(data)
+}
+
+// CloseWrite closes input, so command sees end of input. Output is still readable.
+func (s *execStream) CloseWrite() error {
+	return s.conn.CloseWrite()
+}
+
+// WriteTo copies stdout and stderr of the command to the writer until command closes output. Terminal stream is raw,
+// otherwise it's multiplexed by stdcopy framing.
+func (s *execStream) WriteTo(out io.Writer) (int64, error) {
+	if s.tty {
+		return io.Copy(out, s.conn.Reader)
+	}
+	return stdcopy.StdCopy(out, out, s.conn.Reader)
+}
+
+// Close connection. Connection ignores context, so pending writes and reads are interrupted only by Close.
+func (s *execStream) Close() error {
+	return s.conn.Conn.Close()
+}
+
+type execState struct {
+	Running  bool
+	ExitCode int
+	Pid      int // process ID on the host
+}
+
+type containerState struct {
+	Running   bool
+	StartedAt time.Time
+	Health    string // health status (starting, healthy, unhealthy), empty if container has no healthcheck
+}
+
+type containerSummary struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+type containerEvent struct {
+	Action string
+	Name   string
+	Labels map[string]string
+}
+
+// isNotFound checks whether the error is caused by missing object, ex. removed container.
+func isNotFound(err error) bool {
+	var notFound errdefs.ErrNotFound
+	return errors.As(err, &notFound)
+}
+
+func (d *dockerAPI) checkVersion(ctx context.Context, logger *slog.Logger) error {
+	server, err := d.cli().ServerVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("get daemon version: %w", err)
+	}
+	clientAPI := d.cli().ClientVersion()
+	logger.Info("docker daemon", "version", server.Version, "api", server.APIVersion, "min_api", server.MinAPIVersion, "sdk", sdkVersion(), "client_api", clientAPI)
+	if versions.LessThan(server.APIVersion, clientAPI) {
+		logger.Warn("client API is newer than daemon API, some calls may fail", "client_api", clientAPI, "api", server.APIVersion)
+	}
+	return nil
+}
+
+func (d *dockerAPI) ping(ctx context.Context) error {
+	_, err := d.cli().Ping(ctx)
+	return err
+}
+
+// negotiate API version with daemon. No-op if version was set explicitly.
+func (d *dockerAPI) negotiate(ctx context.Context) {
+	d.cli().NegotiateAPIVersion(ctx)
+}
+
+func (d *dockerAPI) list(ctx context.Context, labels ...string) ([]containerSummary, error) {
+	args := filters.NewArgs()
+	for _, label := range labels {
+		args.Add("label", label)
+	}
+	list, err := d.cli().ContainerList(ctx, types.ContainerListOptions{
+		Filters: args,
+		All:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]containerSummary, 0, len(list))
+	for _, c := range list {
+		var name string
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		ans = append(ans, containerSummary{
+			ID:     c.ID,
+			Name:   name,
+			Labels: c.Labels,
+		})
+	}
+	return ans, nil
+}
+
+// events streams start and destroy events of containers with the labels. Stream stops after first error.
+func (d *dockerAPI) events(ctx context.Context, labels ...string) (<-chan containerEvent, <-chan error) {
+	args := filters.NewArgs(
+		filters.Arg("type", "container"),
+		filters.Arg("event", "start"),
+		filters.Arg("event", "destroy"),
+	)
+	for _, label := range labels {
+		args.Add("label", label)
+	}
+	messages, errs := d.cli().Events(ctx, types.EventsOptions{Filters: args})
+	out := make(chan containerEvent)
+	outErr := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case msg := <-messages:
+				attrs := msg.Actor.Attributes
+				select {
+				case out <- containerEvent{Action: msg.Action, Name: attrs["name"], Labels: attrs}:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				outErr <- err
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
+
+func (d *dockerAPI) labels(ctx context.Context, containerID string) (map[string]string, error) {
+	info, err := d.cli().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return info.Config.Labels, nil
+}
+
+func (d *dockerAPI) state(ctx context.Context, containerID string) (containerState, error) {
+	info, err := d.cli().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return containerState{}, err
+	}
+	var state containerState
+	if info.State != nil {
+		state.Running = info.State.Running
+		state.StartedAt, _ = time.Parse(time.RFC3339Nano, info.State.StartedAt)
+		if info.State.Health != nil {
+			state.Health = info.State.Health.Status
+		}
+	}
+	return state, nil
+}
+
+// clone creates new container from configuration of the template container: same image, command, environment,
+// mounts and network mode, but without published ports, restart policy and scheduler labels.
+func (d *dockerAPI) clone(ctx context.Context, templateID string, name string) (string, error) {
+	info, err := d.cli().ContainerInspect(ctx, templateID)
+	if err != nil {
+		return "", fmt.Errorf("inspect template: %w", err)
+	}
+	config := *info.Config
+	config.Hostname = ""
+	config.ExposedPorts = nil
+	config.Labels = make(map[string]string, len(info.Config.Labels))
+	for k, v := range info.Config.Labels {
+		if !strings.HasPrefix(k, labelPrefix) {
+			config.Labels[k] = v
+		}
+	}
+	config.Labels[composeOneoffLabel] = "True"
+
+	var hostConfig container.HostConfig
+	if info.HostConfig != nil {
+		hostConfig = *info.HostConfig
+	}
+	hostConfig.PortBindings = nil
+	hostConfig.PublishAllPorts = false
+	hostConfig.AutoRemove = false
+	hostConfig.RestartPolicy = container.RestartPolicy{Name: "no"}
+
+	created, err := d.cli().ContainerCreate(ctx, &config, &hostConfig, nil, nil, name)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// remove container with its anonymous volumes, running container is killed.
+func (d *dockerAPI) remove(ctx context.Context, containerID string) error {
+	return d.cli().ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+}
+
+// logs copies stdout and stderr of the container since the time to the writer.
+func (d *dockerAPI) logs(ctx context.Context, containerID string, since time.Time, out io.Writer) error {
+	info, err := d.cli().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	reader, err := d.cli().ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(out, reader)
+	} else {
+		_, err = stdcopy.StdCopy(out, out, reader)
+	}
+	return err
+}
+
+func (d *dockerAPI) start(ctx context.Context, containerID string) error {
+	return d.cli().ContainerStart(ctx, containerID, types.ContainerStartOptions{})
+}
+
+// stop container gracefully: daemon sends SIGTERM (or configured stop signal) and SIGKILL after grace period.
+func (d *dockerAPI) stop(ctx context.Context, containerID string, grace time.Duration) error {
+	return d.cli().ContainerStop(ctx, containerID, &grace)
+}
+
+// restart container: stop gracefully (killed after grace period) and start again.
+func (d *dockerAPI) restart(ctx context.Context, containerID string, grace time.Duration) error {
+	return d.cli().ContainerRestart(ctx, containerID, &grace)
+}
+
+func (d *dockerAPI) kill(ctx context.Context, containerID string) error {
+	return d.signal(ctx, containerID, "SIGKILL")
+}
+
+func (d *dockerAPI) signal(ctx context.Context, containerID string, signal string) error {
+	return d.cli().ContainerKill(ctx, containerID, signal)
+}
+
+// wait until container stopped and returns status code.
+func (d *dockerAPI) wait(ctx context.Context, containerID string) (int64, error) {
+	ok, failed := d.cli().ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case res := <-ok:
+		if res.Error != nil {
+			return 0, errors.New(res.Error.Message)
+		}
+		return res.StatusCode, nil
+	case err := <-failed:
+		return 0, err
+	}
+}
+
+func (d *dockerAPI) execCreate(ctx context.Context, containerID string, spec execSpec) (string, error) {
+	execID, err := d.cli().ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          spec.Cmd,
+		Env:          spec.Env,
+		User:         spec.User,
+		WorkingDir:   spec.WorkingDir,
+		AttachStderr: spec.Attach,
+		AttachStdout: spec.Attach,
+		AttachStdin:  spec.Stdin,
+		Privileged:   spec.Privileged,
+		Tty:          spec.Tty,
+	})
+	if err != nil {
+		return "", err
+	}
+	return execID.ID, nil
+}
+
+func (d *dockerAPI) execStart(ctx context.Context, execID string, tty bool) error {
+	return d.cli().ContainerExecStart(ctx, execID, types.ExecStartCheck{Tty: tty})
+}
+
+// execAttach starts exec with attached streams. Stream must be closed by caller.
+func (d *dockerAPI) execAttach(ctx context.Context, execID string, tty bool) (*execStream, error) {
+	conn, err := d.cli().ContainerExecAttach(ctx, execID, types.ExecStartCheck{Tty: tty})
+	if err != nil {
+		return nil, err
+	}
+	return &execStream{conn: conn, tty: tty}, nil
+}
+
+func (d *dockerAPI) execInspect(ctx context.Context, execID string) (execState, error) {
+	inspect, err := d.cli().ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return execState{}, err
+	}
+	return execState{
+		Running:  inspect.Running,
+		ExitCode: inspect.ExitCode,
+		Pid:      inspect.Pid,
+	}, nil
+}
+
+func sdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == dockerModule {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// withTLS configures client certificates from directory with ca.pem, cert.pem and key.pem, like DOCKER_CERT_PATH.
+func withTLS(certPath string, verify bool) client.Opt {
+	return func(c *client.Client) error {
+		config, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             filepath.Join(certPath, "ca.pem"),
+			CertFile:           filepath.Join(certPath, "cert.pem"),
+			KeyFile:            filepath.Join(certPath, "key.pem"),
+			InsecureSkipVerify: !verify,
+		})
+		if err != nil {
+			return fmt.Errorf("load TLS certificates from %s: %w", certPath, err)
+		}
+		// copy of HTTP client shares transport with the Docker client
+		transport, ok := c.HTTPClient().Transport.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("apply TLS config to transport %T", c.HTTPClient().Transport)
+		}
+		transport.TLSClientConfig = config
+		return nil
+	}
+}
@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// fakeDocker is minimal Docker daemon served over HTTP, so tests use the real SDK client against controlled state.
+type fakeDocker struct {
+	server     *httptest.Server
+	apiVersion string // advertised by ping
+
+	lock       sync.Mutex
+	containers []fakeContainer
+	execs      map[string]*fakeExec
+	versions   []string // API versions of versioned requests
+	exitCode   int      // exit code of created execs
+	attach     func(conn net.Conn, exec *fakeExec)
+}
+
+type fakeContainer struct {
+	ID      string
+	Name    string
+	Labels  map[string]string
+	Running bool
+	Health  string // healthcheck status, no healthcheck if empty
+}
+
+type fakeExec struct {
+	ID        string
+	Container string
+	Config    types.ExecConfig
+	Running   bool
+	ExitCode  int
+}
+
+var versionPrefix = regexp.MustCompile(`^/v([0-9.]+)(/.*)$`)
+
+// newFakeDocker starts fake daemon. Exec commands finish immediately without output unless attach handler is set.
+func newFakeDocker(t *testing.T, containers ...fakeContainer) *fakeDocker {
+	fd := &fakeDocker{apiVersion: "1.41", containers: containers, execs: make(map[string]*fakeExec)}
+	fd.server = httptest.NewServer(http.HandlerFunc(fd.serve))
+	t.Cleanup(fd.server.Close)
+	return fd
+}
+
+// host of the daemon for WithDockerHost.
+func (fd *fakeDocker) host() string {
+	return "tcp://" + fd.server.Listener.Addr().String()
+}
+
+func (fd *fakeDocker) exec(id string) *fakeExec {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	return fd.execs[id]
+}
+
+// lastExec returns the latest created exec.
+func (fd *fakeDocker) lastExec() *fakeExec {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	var last *fakeExec
+	for _, e := range fd.execs {
+		if last == nil || e.ID > last.ID {
+			last = e
+		}
+	}
+	return last
+}
+
+func (fd *fakeDocker) usedVersions() []string {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	return append([]string(nil), fd.versions...)
+}
+
+func (fd *fakeDocker) serve(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	if m := versionPrefix.FindStringSubmatch(path); m != nil {
+		fd.lock.Lock()
+		fd.versions = append(fd.versions, m[1])
+		fd.lock.Unlock()
+		path = m[2]
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/_ping":
+		w.Header().Set("API-Version", fd.apiVersion)
+		_, _ = io.WriteString(w, "OK")
+	case path == "/version":
+		writeJSON(w, http.StatusOK, types.Version{Version: "fake", APIVersion: fd.apiVersion, MinAPIVersion: "1.12"})
+	case path == "/containers/json":
+		fd.listContainers(w, req)
+	case len(parts) == 3 && parts[0] == "containers" && parts[2] == "json":
+		fd.inspectContainer(w, parts[1])
+	case len(parts) == 3 && parts[0] == "containers" && parts[2] == "exec":
+		fd.createExec(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "exec" && parts[2] == "start":
+		fd.startExec(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "exec" && parts[2] == "json":
+		fd.inspectExec(w, parts[1])
+	default:
+		http.Error(w, `{"message":"not implemented"}`, http.StatusNotImplemented)
+	}
+}
+
+// listContainers supports only label filters, as "key" or "key=value".
+func (fd *fakeDocker) listContainers(w http.ResponseWriter, req *http.Request) {
+	args, err := filters.FromJSON(req.URL.Query().Get("filters"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	var list = make([]types.Container, 0, len(fd.containers))
+	for _, c := range fd.containers {
+		if args.MatchKVList("label", c.Labels) {
+			list = append(list, types.Container{ID: c.ID, Names: []string{"/" + c.Name}, Labels: c.Labels})
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (fd *fakeDocker) inspectContainer(w http.ResponseWriter, id string) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	for _, c := range fd.containers {
+		if c.ID == id {
+			state := &types.ContainerState{Running: c.Running, StartedAt: "2023-01-20T11:10:39Z"}
+			if c.Health != "" {
+				state.Health = &types.Health{Status: c.Health}
+			}
+			writeJSON(w, http.StatusOK, types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:    c.ID,
+					Name:  "/" + c.Name,
+					State: state,
+				},
+				Config: &container.Config{Labels: c.Labels},
+			})
+			return
+		}
+	}
+	http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+}
+
+func (fd *fakeDocker) createExec(w http.ResponseWriter, req *http.Request, containerID string) {
+	var config types.ExecConfig
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fd.lock.Lock()
+	id := fmt.Sprintf("exec%04d", len(fd.execs))
+	fd.execs[id] = &fakeExec{ID: id, Container: containerID, Config: config, ExitCode: fd.exitCode}
+	fd.lock.Unlock()
+	writeJSON(w, http.StatusCreated, types.IDResponse{ID: id})
+}
+
+// startExec runs attach handler on hijacked connection for attached exec, detached exec finishes immediately.
+func (fd *fakeDocker) startExec(w http.ResponseWriter, req *http.Request, id string) {
+	e := fd.exec(id)
+	if e == nil {
+		http.Error(w, `{"message":"no such exec"}`, http.StatusNotFound)
+		return
+	}
+	fd.lock.Lock()
+	attach := fd.attach
+	fd.lock.Unlock()
+	if req.Header.Get("Upgrade") == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	_ = buf.Flush()
+	fd.lock.Lock()
+	e.Running = true
+	fd.lock.Unlock()
+	if attach != nil {
+		attach(conn, e)
+	}
+	fd.lock.Lock()
+	e.Running = false
+	fd.lock.Unlock()
+}
+
+func (fd *fakeDocker) inspectExec(w http.ResponseWriter, id string) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	e, ok := fd.execs[id]
+	if !ok {
+		http.Error(w, `{"message":"no such exec"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.ContainerExecInspect{ExecID: e.ID, ContainerID: e.Container, Running: e.Running, ExitCode: e.ExitCode, Pid: 42})
+}
+
+// testContainer of service in project "test" with scheduler labels given as key=value pairs without label prefix.
+func testContainer(id, service string, labels ...string) fakeContainer {
+	var all = map[string]string{
+		composeProjectLabel: "test",
+		composeServiceLabel: service,
+	}
+	for _, kv := range labels {
+		key, value, _ := strings.Cut(kv, "=")
+		all[labelPrefix+key] = value
+	}
+	return fakeContainer{ID: id, Name: "test-" + service + "-1", Labels: all, Running: true}
+}
+
+// newTestScheduler creates scheduler connected to the fake daemon, scoped to project "test".
+func newTestScheduler(t *testing.T, fd *fakeDocker, options ...Option) *Scheduler {
+	t.Helper()
+	base := []Option{WithDockerHost(fd.host()), WithProject("test"), WithLogger(discardLogger()), WithHostname("test")}
+	sc, err := Create(context.Background(), append(base, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sc.Close() })
+	return sc
+}
+
+// recordingNotifier keeps delivered payloads.
+type recordingNotifier struct {
+	lock     sync.Mutex
+	payloads []*Payload
+}
+
+func (rn *recordingNotifier) Notify(_ context.Context, record *Payload) error {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.payloads = append(rn.payloads, record)
+	return nil
+}
+
+func (rn *recordingNotifier) list() []*Payload {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	return append([]*Payload(nil), rn.payloads...)
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
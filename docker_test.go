@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAPIVersionNegotiatedWithDaemon(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	fd.apiVersion = "1.40"
+	sc := newTestScheduler(t, fd)
+	if err := sc.Trigger(context.Background(), "", "web", "", false); err != nil {
+		t.Fatal(err)
+	}
+	assertVersions(t, fd, "1.40")
+}
+
+func TestAPIVersionPinned(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	fd.apiVersion = "1.40"
+	sc := newTestScheduler(t, fd, WithAPIVersion("1.39"))
+	if err := sc.Trigger(context.Background(), "", "web", "", false); err != nil {
+		t.Fatal(err)
+	}
+	assertVersions(t, fd, "1.39")
+}
+
+func assertVersions(t *testing.T, fd *fakeDocker, expected string) {
+	t.Helper()
+	used := fd.usedVersions()
+	if len(used) == 0 {
+		t.Fatal("no versioned requests")
+	}
+	for _, v := range used {
+		if v != expected {
+			t.Fatalf("expected all requests with API %s, got %v", expected, used)
+		}
+	}
+}
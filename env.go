@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var secretMarkers = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "AUTH"}
+
+// parseEnv parses KEY=VALUE pairs separated by newlines or, if value has no newlines, by commas. JSON array of
+// strings is also accepted to allow both commas and newlines in values.
+func parseEnv(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	var entries []string
+	switch {
+	case strings.HasPrefix(value, "["):
+		if err := json.Unmarshal([]byte(value), &entries); err != nil {
+			return nil, fmt.Errorf("parse JSON: %w", err)
+		}
+	case strings.Contains(value, "\n"):
+		entries = strings.Split(value, "\n")
+	default:
+		entries = strings.Split(value, ",")
+	}
+	var ans = make([]string, 0, len(entries))
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("entry %d: expected KEY=VALUE, got %q", i+1, entry)
+		}
+		ans = append(ans, entry)
+	}
+	return ans, nil
+}
+
+// forwardedEnv returns variables of the scheduler environment with the prefix, except variables defined by the job.
+func forwardedEnv(prefix string, environ, jobEnv []string) []string {
+	var defined = make(map[string]bool, len(jobEnv))
+	for _, entry := range jobEnv {
+		key, _, _ := strings.Cut(entry, "=")
+		defined[key] = true
+	}
+	var ans []string
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, prefix) && !defined[key] {
+			ans = append(ans, entry)
+		}
+	}
+	return ans
+}
+
+// maskEnv hides values of variables which look like secrets, for logging.
+func maskEnv(env []string) []string {
+	var ans = make([]string, 0, len(env))
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		upper := strings.ToUpper(key)
+		for _, marker := range secretMarkers {
+			if strings.Contains(upper, marker) {
+				entry = key + "=***"
+				break
+			}
+		}
+		ans = append(ans, entry)
+	}
+	return ans
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestExecCancelledDuringOutputStreaming(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	streaming := make(chan struct{})
+	fd.attach = func(conn net.Conn, _ *fakeExec) {
+		_, _ = stdcopy.NewStdWriter(conn, stdcopy.Stdout).Write([]byte("working\n"))
+		close(streaming)
+		_, _ = io.Copy(io.Discard, conn) // blocks until scheduler closes connection
+	}
+	notifier := &recordingNotifier{}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifyOn(NotifyAlways))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-streaming
+		cancel()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- sc.Trigger(ctx, "", "web", "", false)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run is not interrupted by cancellation")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled run, got %v", err)
+	}
+	payloads := notifier.list()
+	if len(payloads) != 1 || payloads[0].Reason != ReasonCancelled || !payloads[0].Failed {
+		t.Fatalf("expected failed notification with reason %s, got %+v", ReasonCancelled, payloads)
+	}
+	if frame := waitNoGoroutine(t, "(*execStream).WriteTo"); frame != "" {
+		t.Fatalf("output copying outlived the run:\n%s", frame)
+	}
+}
+
+// waitNoGoroutine waits until there is no goroutine with the function in stack and returns the stack otherwise.
+func waitNoGoroutine(t *testing.T, function string) string {
+	t.Helper()
+	var stack string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		buf := make([]byte, 1<<20)
+		stack = ""
+		for _, g := range strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n") {
+			if strings.Contains(g, function) {
+				stack = g
+			}
+		}
+		if stack == "" {
+			return ""
+		}
+	}
+	return stack
+}
+
+func TestExecNonZeroExitWithoutLogsFails(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=exit 3"))
+	fd.exitCode = 3
+	notifier := &recordingNotifier{}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifyOn(NotifyAlways))
+
+	tasks, err := sc.Tasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Logging {
+		t.Fatalf("expected single task without logs, got %+v", tasks)
+	}
+	err = sc.runJob(context.Background(), newJob(tasks[0]), &run{id: newRunID()})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected exit error with code 3, got %v", err)
+	}
+	if e := fd.lastExec(); e == nil || e.Config.AttachStdout {
+		t.Fatalf("expected detached exec, got %+v", e)
+	}
+	payloads := notifier.list()
+	if len(payloads) != 1 || !payloads[0].Failed || payloads[0].ExitCode == nil || *payloads[0].ExitCode != 3 {
+		t.Fatalf("expected failed notification with exit code 3, got %+v", payloads)
+	}
+}
+
+func TestExecUserAndWorkdirFromLabels(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true", "user=1000:1000", "workdir=/srv/app"))
+	sc := newTestScheduler(t, fd)
+	if err := sc.Trigger(context.Background(), "", "web", "", false); err != nil {
+		t.Fatal(err)
+	}
+	e := fd.lastExec()
+	if e == nil {
+		t.Fatal("exec not created")
+	}
+	if e.Config.User != "1000:1000" || e.Config.WorkingDir != "/srv/app" {
+		t.Fatalf("expected user 1000:1000 in /srv/app, got user %q in %q", e.Config.User, e.Config.WorkingDir)
+	}
+}
+
+func TestExecPrivilegedFromLabel(t *testing.T) {
+	for _, privileged := range []bool{false, true} {
+		fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true", fmt.Sprintf("privileged=%v", privileged)))
+		sc := newTestScheduler(t, fd)
+		if err := sc.Trigger(context.Background(), "", "web", "", false); err != nil {
+			t.Fatal(err)
+		}
+		if e := fd.lastExec(); e == nil || e.Config.Privileged != privileged {
+			t.Fatalf("expected privileged=%v, got %+v", privileged, e)
+		}
+	}
+}
+
+func TestExecExpectMatchesOnlyCurrentAttempt(t *testing.T) {
+	for _, c := range []struct {
+		expect string
+		first  string // output of the first, failed attempt
+		second string // output of the second, successful attempt
+		passed bool
+	}{
+		{"OK", "OK", "not ready", false},
+		{"/^OK$/", "not ready", "OK", true},
+	} {
+		fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true", "expect="+c.expect, "retries=1", "retry-interval=10ms"))
+		fd.attach = func(conn net.Conn, e *fakeExec) {
+			out, code := c.first, 1
+			if e.ID != "exec0000" {
+				out, code = c.second, 0
+			}
+			fd.lock.Lock()
+			e.ExitCode = code
+			fd.lock.Unlock()
+			_, _ = stdcopy.NewStdWriter(conn, stdcopy.Stdout).Write([]byte(out))
+		}
+		sc := newTestScheduler(t, fd)
+
+		err := sc.Trigger(context.Background(), "", "web", "", false)
+		if passed := err == nil; passed != c.passed {
+			t.Fatalf("expect %s after %q and %q: expected passed=%v, got %v", c.expect, c.first, c.second, c.passed, err)
+		}
+		if !c.passed && !errors.Is(err, errUnexpectedOutput) {
+			t.Fatalf("expected unexpected output, got %v", err)
+		}
+		if e := fd.lastExec(); e == nil || e.ID != "exec0001" {
+			t.Fatalf("expected two attempts, last exec %+v", e)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	healthPingInterval      = 15 * time.Second
+	healthPingTimeout       = 5 * time.Second
+	dockerReconnectFailures = 3 // consecutive failed pings before Docker client is recreated
+)
+
+// health tracks scheduler state for readiness and liveness probes. Methods are safe on nil health.
+type health struct {
+	lock          sync.Mutex
+	started       bool
+	jobs          int
+	discoveryErr  error
+	lastDiscovery time.Time
+	pingErr       error
+	lastPing      time.Time
+}
+
+type healthStatus struct {
+	Healthy       bool      `json:"healthy"`
+	Project       string    `json:"project"`
+	Jobs          int       `json:"jobs"`
+	LastDiscovery time.Time `json:"last_discovery"`
+	LastPing      time.Time `json:"last_ping"` // last check of Docker daemon connection
+	Error         string    `json:"error,omitempty"`
+}
+
+func (h *health) discovered(jobs int, err error) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.discoveryErr = err
+	if err == nil {
+		h.jobs = jobs
+		h.lastDiscovery = time.Now()
+	}
+}
+
+func (h *health) start() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.started = true
+}
+
+func (h *health) pinged(err error) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pingErr = err
+	h.lastPing = time.Now()
+}
+
+func (h *health) status(project string) healthStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	status := healthStatus{
+		Project:       project,
+		Jobs:          h.jobs,
+		LastDiscovery: h.lastDiscovery,
+		LastPing:      h.lastPing,
+	}
+	switch {
+	case !h.started:
+		status.Error = "not started"
+	case h.discoveryErr != nil:
+		status.Error = "discovery: " + h.discoveryErr.Error()
+	case h.pingErr != nil:
+		status.Error = "docker: " + h.pingErr.Error()
+	default:
+		status.Healthy = true
+	}
+	return status
+}
+
+// pingDocker periodically checks connection to Docker daemon until context is cancelled. After several failed pings
+// in a row Docker client is recreated, so connection is restored once daemon is back (ex: after upgrade).
+func (sc *Scheduler) pingDocker(ctx context.Context) {
+	ticker := time.NewTicker(healthPingInterval)
+	defer ticker.Stop()
+	var failures int
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		err := sc.docker.ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		sc.health.pinged(err)
+		switch {
+		case err == nil && failures > 0:
+			sc.logger.Info("docker daemon is reachable again", "failed_pings", failures)
+			failures = 0
+		case err != nil:
+			failures++
+			sc.logger.Warn("docker daemon is not reachable", "failed_pings", failures, "error", err)
+			if failures%dockerReconnectFailures == 0 {
+				sc.reconnectDocker(ctx)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// serveHealth responds 200 if jobs are scheduled and Docker is reachable, otherwise 503.
+func (sc *Scheduler) serveHealth(writer http.ResponseWriter, _ *http.Request) {
+	status := sc.health.status(strings.Join(sc.projects, ","))
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(writer, code, status)
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnhealthySkipIsNotifiedWithReason(t *testing.T) {
+	c := testContainer("c1", "db", "cron=@daily", "exec=true", "require-healthy=true")
+	c.Health = "unhealthy"
+	fd := newFakeDocker(t, c)
+	notifier := &recordingNotifier{}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifySkipped())
+
+	if err := sc.Trigger(context.Background(), "", "db", "", false); err != nil {
+		t.Fatalf("skipped run must not fail: %v", err)
+	}
+	if e := fd.lastExec(); e != nil {
+		t.Fatalf("command must not be executed, got exec %s", e.ID)
+	}
+	payloads := notifier.list()
+	if len(payloads) != 1 {
+		t.Fatalf("expected one notification, got %d", len(payloads))
+	}
+	if p := payloads[0]; !p.Skipped || p.Failed || p.Reason != ReasonUnhealthy {
+		t.Fatalf("expected skipped notification with reason %s, got skipped=%v failed=%v reason=%s", ReasonUnhealthy, p.Skipped, p.Failed, p.Reason)
+	}
+}
+
+func TestUnhealthySkipIsNotNotifiedByDefault(t *testing.T) {
+	c := testContainer("c1", "db", "cron=@daily", "exec=true", "require-healthy=true")
+	c.Health = "starting"
+	fd := newFakeDocker(t, c)
+	notifier := &recordingNotifier{}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifyOn(NotifyAlways))
+
+	if err := sc.Trigger(context.Background(), "", "db", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(notifier.list()); n != 0 {
+		t.Fatalf("expected no notifications, got %d", n)
+	}
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// historyRecord is single line of history file.
+type historyRecord struct {
+	RunID    string    `json:"run_id"`
+	Project  string    `json:"project"`
+	Service  string    `json:"service"`
+	Job      string    `json:"job"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// history appends results of runs to JSONL file, keeping at most max records (unlimited if zero).
+// Methods are safe on nil history.
+type history struct {
+	path  string
+	max   int
+	lock  sync.Mutex
+	count int // records in file
+}
+
+func openHistory(path string, max int) (*history, error) {
+	h := &history{path: path, max: max}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.count = bytes.Count(data, []byte("\n"))
+	return h, nil
+}
+
+func (h *history) add(record historyRecord) error {
+	if h == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	line = append(line, '\n')
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	h.count++
+	if h.max > 0 && h.count > h.max {
+		return h.trim()
+	}
+	return nil
+}
+
+// trim removes the oldest records above limit. File is replaced atomically.
+func (h *history) trim() error {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) > h.max {
+		lines = lines[len(lines)-h.max:]
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(bytes.Join(lines, nil), '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("replace: %w", err)
+	}
+	h.count = len(lines)
+	return nil
+}
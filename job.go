@@ -0,0 +1,275 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	prevStatusEnv   = "SCHEDULER_PREV_STATUS"
+	prevFinishedEnv = "SCHEDULER_PREV_FINISHED"
+)
+
+var errTaskRunning = errors.New("task is running")
+
+// errSkipped returned (wrapped with reason) when run was intentionally not executed.
+var errSkipped = errors.New("skipped")
+
+// errUnhealthy wrapped by errSkipped when container healthcheck isn't passed. Unlike other skips it can be notified.
+var errUnhealthy = errors.New("container is not healthy")
+
+// errTimeout wraps error of run interrupted by timeout of the task.
+var errTimeout = errors.New("timed out")
+
+// errContainerMissing returned when container of the task was removed and there is no replacement.
+var errContainerMissing = errors.New("container no longer exists")
+
+// errUnexpectedOutput returned when command output doesn't match expect label.
+var errUnexpectedOutput = errors.New("output doesn't match expected")
+
+// errAmbiguousService returned by trigger when service is found in several projects and project is not set.
+var errAmbiguousService = errors.New("project must be set")
+
+// ExitError is returned when job command or container exited with non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("non-zero code %d", e.Code)
+}
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultSkipped = "skipped"
+)
+
+// job is scheduled task with its runtime state.
+type job struct {
+	slot    chan struct{} // held while job is running
+	pending chan struct{} // held by run waiting in queue
+	entry   cron.EntryID
+	sched   cron.Schedule // parsed schedule of the task, including spread
+
+	lock sync.Mutex
+	task Task
+	last *result
+}
+
+// result of single job run.
+type result struct {
+	started  time.Time
+	finished time.Time
+	err      error
+}
+
+// run is single execution of the job.
+type run struct {
+	id     string
+	task   Task
+	env    []string
+	output *tailBuffer // captured output, only if logging enabled
+	ticket *ticket     // reserved in advance, reserved by run itself if nil
+	delay  time.Duration
+	tries  int // execution attempts made so far
+	logger *slog.Logger
+}
+
+// execSpec of the run command with optional attached output.
+func (r *run) execSpec(attach bool) execSpec {
+	return execSpec{
+		Cmd:        r.task.Command,
+		Env:        r.env,
+		User:       r.task.user,
+		WorkingDir: r.task.workdir,
+		Attach:     attach,
+		Stdin:      r.task.stdin != "",
+		Privileged: r.task.privileged,
+		Tty:        r.task.tty,
+	}
+}
+
+// newRunID generates random ID used to correlate logs and notifications of the single run.
+func newRunID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+func newJob(t Task) *job {
+	return &job{
+		task:    t,
+		slot:    make(chan struct{}, 1),
+		pending: make(chan struct{}, 1),
+	}
+}
+
+// ticket is place of the run reserved according to concurrency policy of the job.
+type ticket struct {
+	job    *job
+	guard  bool // holds slot (or pending slot if queued)
+	queued bool // waits for the running run
+}
+
+// reserve applies concurrency policy of the task without waiting. Ticket must be released once run finished.
+func (j *job) reserve() (*ticket, error) {
+	t := j.current()
+	switch t.Concurrency {
+	case ConcurrencyAllow:
+		return &ticket{job: j}, nil
+	case ConcurrencyQueue:
+		select {
+		case j.slot <- struct{}{}:
+			return &ticket{job: j, guard: true}, nil
+		default:
+		}
+		select {
+		case j.pending <- struct{}{}:
+			return &ticket{job: j, guard: true, queued: true}, nil
+		default:
+			return nil, fmt.Errorf("%w and another run is queued; dropped by %s policy", errTaskRunning, t.Concurrency)
+		}
+	default:
+		select {
+		case j.slot <- struct{}{}:
+			return &ticket{job: j, guard: true}, nil
+		default:
+			return nil, fmt.Errorf("%w; dropped by %s policy", errTaskRunning, ConcurrencyForbid)
+		}
+	}
+}
+
+// wait until queued run may start. No-op for not queued ticket.
+func (tk *ticket) wait(ctx context.Context, logger *slog.Logger) error {
+	if !tk.queued {
+		return nil
+	}
+	logger.Info("job is running, run delayed by policy", "policy", tk.job.current().Concurrency)
+	defer func() {
+		<-tk.job.pending
+		tk.queued = false
+	}()
+	select {
+	case tk.job.slot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		tk.guard = false
+		return ctx.Err()
+	}
+}
+
+func (tk *ticket) release() {
+	switch {
+	case !tk.guard:
+	case tk.queued:
+		<-tk.job.pending
+	default:
+		<-tk.job.slot
+	}
+	tk.guard = false
+}
+
+func (j *job) current() Task {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.task
+}
+
+func (j *job) update(t Task) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.task = t
+}
+
+// relocate the task to recreated container, unless the task was already updated by re-discovery.
+func (j *job) relocate(oldID, newID string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.task.Container == oldID {
+		j.task.Container = newID
+	}
+}
+
+func (j *job) lastResult() (result, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.last == nil {
+		return result{}, false
+	}
+	return *j.last, true
+}
+
+// setLastResult saves result of the run and returns result of previous run, if any.
+func (j *job) setLastResult(res result) (result, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	prev := j.last
+	j.last = &res
+	if prev == nil {
+		return result{}, false
+	}
+	return *prev, true
+}
+
+// statusEnv returns environment variables describing previous run of the job.
+func (j *job) statusEnv() []string {
+	last, ok := j.lastResult()
+	if !ok {
+		return []string{prevStatusEnv + "=none"}
+	}
+	status := "success"
+	if last.err != nil {
+		status = "failure"
+	}
+	return []string{
+		prevStatusEnv + "=" + status,
+		prevFinishedEnv + "=" + last.finished.Format(time.RFC3339),
+	}
+}
+
+// reasonOf classifies run error for notifications. Exit code is set only for command or container exited with
+// unsuccessful code.
+func reasonOf(err error) (string, *int) {
+	var exitErr *ExitError
+	switch {
+	case err == nil:
+		return ReasonOK, nil
+	case errors.Is(err, errTimeout):
+		return ReasonTimeout, nil
+	case errors.As(err, &exitErr):
+		code := exitErr.Code
+		return ReasonNonZeroExit, &code
+	case errors.Is(err, errUnhealthy):
+		return ReasonUnhealthy, nil
+	case errors.Is(err, errSkipped), errors.Is(err, errTaskRunning):
+		return ReasonSkipped, nil
+	case errors.Is(err, errUnexpectedOutput):
+		return ReasonUnexpectedOutput, nil
+	case errors.Is(err, errContainerMissing), isNotFound(err):
+		return ReasonContainerMissing, nil
+	case errors.Is(err, context.Canceled):
+		return ReasonCancelled, nil
+	default:
+		return ReasonDockerError, nil
+	}
+}
+
+func resultOf(err error) string {
+	switch {
+	case err == nil:
+		return resultSuccess
+	case errors.Is(err, errSkipped), errors.Is(err, errTaskRunning):
+		return resultSkipped
+	default:
+		return resultFailure
+	}
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	labelPrefix    = "net.reddec.scheduler."
+	defaultJobName = "default"
+)
+
+// Label keys relative to job namespace: net.reddec.scheduler.<key> for default job
+// and net.reddec.scheduler.<name>.<key> for named jobs.
+const (
+	cronKey        = "cron"
+	execKey        = "exec"
+	execFileKey    = "exec-file"
+	logsKey        = "logs"
+	artifactsKey   = "artifacts"
+	prevStatusKey  = "prev-status"
+	timeoutKey     = "timeout"
+	modeKey        = "mode"
+	minUptimeKey   = "min-uptime"
+	concurrencyKey = "concurrency"
+	leaderOnlyKey  = "leader-only"
+	timezoneKey    = "timezone"
+	envKey         = "env"
+	userKey        = "user"
+	workdirKey     = "workdir"
+	notifyKey      = "notify"
+	jitterKey      = "jitter"
+	disabledKey    = "disabled"
+	healthyKey     = "require-healthy"
+	stdinKey       = "stdin"
+	catchupKey     = "catchup"
+	signalKey      = "signal"
+	shellKey       = "shell"
+	replicasKey    = "replicas"
+	successKey     = "success-codes"
+	maxLogKey      = "max-log-bytes"
+	onStartKey     = "on-start"
+	stopOnStartKey = "stop-on-start"
+	skipRunKey     = "skip-running"
+	expectKey      = "expect"
+	outputFileKey  = "output-file"
+	initDelayKey   = "initial-delay"
+	privilegedKey  = "privileged"
+	ttyKey         = "tty"
+	retriesKey     = "retries"
+	retryIntKey    = "retry-interval"
+	notifyURLKey   = "notify.url"
+	notifyAuthKey  = "notify.authorization"
+)
+
+// knownKeys are all supported label keys, used to detect typos.
+//
+//nolint:gochecknoglobals
+var knownKeys = map[string]bool{
+	cronKey: true, execKey: true, execFileKey: true, logsKey: true, artifactsKey: true, prevStatusKey: true,
+	timeoutKey: true, modeKey: true, minUptimeKey: true, concurrencyKey: true, leaderOnlyKey: true, timezoneKey: true,
+	envKey: true, userKey: true, workdirKey: true, notifyKey: true, jitterKey: true, disabledKey: true, healthyKey: true,
+	stdinKey: true, catchupKey: true, signalKey: true, shellKey: true, replicasKey: true, successKey: true,
+	maxLogKey: true, onStartKey: true, stopOnStartKey: true, privilegedKey: true, ttyKey: true, retriesKey: true,
+	retryIntKey: true, notifyURLKey: true, notifyAuthKey: true, skipRunKey: true,
+	expectKey: true, outputFileKey: true, initDelayKey: true,
+}
+
+// unknownLabels returns sorted scheduler labels which are neither known keys of default job nor known keys of
+// declared named jobs, ex. net.reddec.scheduler.corn.
+func unknownLabels(labels map[string]string) []string {
+	var names []string
+	for key := range labels {
+		rest, scoped := strings.CutPrefix(key, labelPrefix)
+		if name, ok := strings.CutSuffix(rest, "."+cronKey); scoped && ok {
+			names = append(names, name)
+		}
+	}
+	var ans []string
+	for key := range labels {
+		rest, ok := strings.CutPrefix(key, labelPrefix)
+		if !ok || knownKeys[rest] {
+			continue
+		}
+		var known bool
+		for _, name := range names {
+			if sub, ok := strings.CutPrefix(rest, name+"."); ok && knownKeys[sub] {
+				known = true
+				break
+			}
+		}
+		if !known {
+			ans = append(ans, key)
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// jobLabels is view of container labels scoped to single job.
+type jobLabels struct {
+	name   string
+	prefix string
+	labels map[string]string
+}
+
+func (jl jobLabels) get(key string) string {
+	return jl.labels[jl.prefix+key]
+}
+
+func (jl jobLabels) bool(key string) bool {
+	v, err := strconv.ParseBool(jl.get(key))
+	return err == nil && v
+}
+
+// strictBool parses optional boolean label, false if not set. Unlike bool, invalid value is an error.
+func (jl jobLabels) strictBool(key string) (bool, error) {
+	v := jl.get(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// duration parses optional duration label, zero if not set.
+func (jl jobLabels) duration(key string) (time.Duration, error) {
+	v := jl.get(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// declaredJobs returns all jobs declared in container labels ordered by name. Job is declared by cron label:
+// net.reddec.scheduler.cron for default job or net.reddec.scheduler.<name>.cron for named job.
+func declaredJobs(labels map[string]string) ([]jobLabels, error) {
+	var ans []jobLabels
+	for key := range labels {
+		rest, scoped := strings.CutPrefix(key, labelPrefix)
+		name, ok := strings.CutSuffix(rest, "."+cronKey) // cron label of default job has no dot before key
+		if !scoped || !ok || name == "" || strings.Contains(name, ".") {
+			continue
+		}
+		if name == defaultJobName {
+			return nil, fmt.Errorf("job name %q is reserved for unnamed job", defaultJobName)
+		}
+		ans = append(ans, jobLabels{name: name, prefix: labelPrefix + name + ".", labels: labels})
+	}
+	if _, ok := labels[labelPrefix+cronKey]; ok {
+		ans = append(ans, jobLabels{name: defaultJobName, prefix: labelPrefix, labels: labels})
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].name < ans[j].name
+	})
+	return ans, nil
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const leaderCheckTimeout = 5 * time.Second
+
+// leaderCheck consults external leader election: HTTP endpoint (2xx means leader) or file with boolean content.
+// Result is cached for short time to avoid hammering coordinator when many jobs fire at once.
+type leaderCheck struct {
+	url   string
+	file  string
+	cache time.Duration
+
+	lock    sync.Mutex
+	checked time.Time
+	leader  bool
+}
+
+func (lc *leaderCheck) isLeader(ctx context.Context) (bool, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if !lc.checked.IsZero() && time.Since(lc.checked) < lc.cache {
+		return lc.leader, nil
+	}
+	leader, err := lc.check(ctx)
+	if err != nil {
+		return false, err
+	}
+	lc.leader = leader
+	lc.checked = time.Now()
+	return leader, nil
+}
+
+func (lc *leaderCheck) check(ctx context.Context) (bool, error) {
+	if lc.file != "" {
+		content, err := os.ReadFile(lc.file)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read leader file: %w", err)
+		}
+		v, err := strconv.ParseBool(strings.TrimSpace(string(content)))
+		if err != nil {
+			return false, fmt.Errorf("parse leader file: %w", err)
+		}
+		return v, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, leaderCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lc.url, nil)
+	if err != nil {
+		return false, fmt.Errorf("create leader request: %w", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("leader request: %w", err)
+	}
+	_ = res.Body.Close()
+	return res.StatusCode/100 == 2, nil
+}
+
+// checkLeader skips run of leader-only job if the scheduler is not the leader.
+func (sc *Scheduler) checkLeader(ctx context.Context, task Task) error {
+	if !task.leaderOnly {
+		return nil
+	}
+	leader, err := sc.leader.isLeader(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: leader check failed: %v", errSkipped, err)
+	}
+	if !leader {
+		return fmt.Errorf("%w: not a leader", errSkipped)
+	}
+	return nil
+}
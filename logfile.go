@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// logFiles keeps output of jobs in <dir>/<project>-<service>-<job>.log instead of scheduler logs. File exceeding max size is
+// rotated to <file>.1, replacing the previous one.
+type logFiles struct {
+	dir     string
+	maxSize int64      // zero means no rotation
+	lock    sync.Mutex // serializes writes and rotation
+}
+
+func (lf *logFiles) path(t Task) string {
+	return filepath.Join(lf.dir, t.Project+"-"+t.Service+"-"+t.Name+".log")
+}
+
+// writer of the run output. Every line is prefixed by time and run ID. Close must be called to flush incomplete line.
+func (lf *logFiles) writer(r *run, clock Clock) *logFileWriter {
+	return &logFileWriter{files: lf, path: lf.path(r.task), runID: r.id, clock: clock, logger: r.logger}
+}
+
+// append data to the file, rotating it before if needed.
+func (lf *logFiles) append(path string, data []byte) error {
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+	if lf.maxSize > 0 {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > lf.maxSize {
+			if err := os.Rename(path, path+".1"); err != nil {
+				return fmt.Errorf("rotate: %w", err)
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	return f.Close()
+}
+
+// logFileWriter writes complete lines of output to the job log file. Write never fails, so the source is drained
+// even if the file is not writable; the first error is logged.
+type logFileWriter struct {
+	files  *logFiles
+	path   string
+	runID  string
+	clock  Clock
+	logger *slog.Logger
+	buf    []byte
+	failed bool
+}
+
+func (lw *logFileWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	i := bytes.LastIndexByte(lw.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lw.flush(lw.buf[:i+1])
+	lw.buf = lw.buf[i+1:]
+	return len(p), nil
+}
+
+func (lw *logFileWriter) Close() error {
+	if len(lw.buf) > 0 {
+		lw.flush(append(lw.buf, '\n'))
+		lw.buf = nil
+	}
+	return nil
+}
+
+func (lw *logFileWriter) flush(lines []byte) {
+	prefix := []byte(lw.clock.Now().Format(time.RFC3339) + " " + lw.runID + " ")
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		out.Write(prefix)
+		out.Write(line)
+	}
+	if err := lw.files.append(lw.path, out.Bytes()); err != nil && !lw.failed {
+		lw.failed = true
+		lw.logger.Error("write job log file failed", "file", lw.path, "error", err)
+	}
+}
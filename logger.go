@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// withLogger returns context carrying logger, used to pass logger with run attributes to notifiers.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns logger from context or default logger.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// lineLogger writes every line of output as separate log record. Close must be called to flush incomplete line.
+// Output above limit (if set) is dropped with truncation marker, but writes still succeed, so the source is drained.
+type lineLogger struct {
+	logger    *slog.Logger
+	limit     int // max bytes to log, zero means no limit
+	buf       []byte
+	written   int
+	truncated bool
+}
+
+func (ll *lineLogger) Write(p []byte) (int, error) {
+	n := len(p)
+	if ll.limit > 0 {
+		left := ll.limit - ll.written
+		if left < len(p) {
+			p = p[:max(left, 0)]
+			defer ll.truncate()
+		}
+		ll.written += len(p)
+	}
+	ll.buf = append(ll.buf, p...)
+	for {
+		i := bytes.IndexByte(ll.buf, '\n')
+		if i < 0 {
+			break
+		}
+		ll.logger.Info("output", "line", string(ll.buf[:i]))
+		ll.buf = ll.buf[i+1:]
+	}
+	return n, nil
+}
+
+func (ll *lineLogger) truncate() {
+	if ll.truncated {
+		return
+	}
+	_ = ll.Close()
+	ll.logger.Warn("output truncated", "limit", ll.limit)
+	ll.truncated = true
+}
+
+func (ll *lineLogger) Close() error {
+	if len(ll.buf) > 0 {
+		ll.logger.Info("output", "line", string(ll.buf))
+		ll.buf = nil
+	}
+	return nil
+}
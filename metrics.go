@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var durationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+type jobKey struct {
+	project string
+	service string
+	job     string
+}
+
+type runKey struct {
+	jobKey
+	result string
+}
+
+type histogram struct {
+	buckets []uint64 // cumulative counts per durationBuckets
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(value float64) {
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(durationBuckets))
+	}
+	for i, le := range durationBuckets {
+		if value <= le {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// metrics of job runs exposed in Prometheus text format.
+type metrics struct {
+	lock      sync.Mutex
+	runs      map[runKey]uint64
+	durations map[jobKey]*histogram
+	running   map[jobKey]int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		runs:      make(map[runKey]uint64),
+		durations: make(map[jobKey]*histogram),
+		running:   make(map[jobKey]int64),
+	}
+}
+
+func (m *metrics) started(t Task) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.running[jobKey{project: t.Project, service: t.Service, job: t.Name}]++
+}
+
+// finished records run result: success, failure, or skipped. Duration of skipped runs is not observed.
+func (m *metrics) finished(t Task, duration time.Duration, res string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := jobKey{project: t.Project, service: t.Service, job: t.Name}
+	m.running[key]--
+	m.runs[runKey{jobKey: key, result: res}]++
+	if res == resultSkipped {
+		return
+	}
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{}
+		m.durations[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+func (m *metrics) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.write(writer)
+}
+
+func (m *metrics) write(out io.Writer) {
+	fmt.Fprintln(out, "# HELP scheduler_job_runs_total Number of finished job runs.")
+	fmt.Fprintln(out, "# TYPE scheduler_job_runs_total counter")
+	runs := make([]runKey, 0, len(m.runs))
+	for k := range m.runs {
+		runs = append(runs, k)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].jobKey != runs[j].jobKey {
+			return runs[i].jobKey.less(runs[j].jobKey)
+		}
+		return runs[i].result < runs[j].result
+	})
+	for _, k := range runs {
+		fmt.Fprintf(out, "scheduler_job_runs_total{%s,result=%s} %d\n", k.labels(), quoteLabel(k.result), m.runs[k])
+	}
+
+	fmt.Fprintln(out, "# HELP scheduler_job_duration_seconds Duration of job runs.")
+	fmt.Fprintln(out, "# TYPE scheduler_job_duration_seconds histogram")
+	for _, k := range sortedKeys(m.durations) {
+		h := m.durations[k]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(out, "scheduler_job_duration_seconds_bucket{%s,le=%s} %d\n", k.labels(), quoteLabel(formatFloat(le)), h.buckets[i])
+		}
+		fmt.Fprintf(out, "scheduler_job_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), h.count)
+		fmt.Fprintf(out, "scheduler_job_duration_seconds_sum{%s} %s\n", k.labels(), formatFloat(h.sum))
+		fmt.Fprintf(out, "scheduler_job_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	fmt.Fprintln(out, "# HELP scheduler_job_running Number of currently running job instances.")
+	fmt.Fprintln(out, "# TYPE scheduler_job_running gauge")
+	for _, k := range sortedKeys(m.running) {
+		fmt.Fprintf(out, "scheduler_job_running{%s} %d\n", k.labels(), m.running[k])
+	}
+}
+
+func (k jobKey) labels() string {
+	return "project=" + quoteLabel(k.project) + ",service=" + quoteLabel(k.service) + ",job=" + quoteLabel(k.job)
+}
+
+func (k jobKey) less(other jobKey) bool {
+	if k.project != other.project {
+		return k.project < other.project
+	}
+	if k.service != other.service {
+		return k.service < other.service
+	}
+	return k.job < other.job
+}
+
+func sortedKeys[T any](m map[jobKey]T) []jobKey {
+	keys := make([]jobKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -5,63 +5,169 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"math/rand"
 	"net/http"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// Notifier delivers result of finished run to single target.
+type Notifier interface {
+	Notify(ctx context.Context, record *Payload) error
+}
+
+// BatchNotifier is notifier which can deliver multiple results at once. Only batch notifiers are used for batching,
+// other notifiers always receive results immediately.
+type BatchNotifier interface {
+	Notifier
+	NotifyBatch(ctx context.Context, records []*Payload) error
+}
+
+// Lifecycle events of the scheduler, see WithNotifyLifecycle. Payload of job runs has no event.
+const (
+	EventStarted = "started" // scheduler started and jobs are scheduled
+	EventStopped = "stopped" // scheduler stopped, running jobs are finished
+)
+
+// Reasons of job run result, see Payload.Reason.
+const (
+	ReasonOK               = "ok"                // run succeeded
+	ReasonNonZeroExit      = "nonzero_exit"      // command or container exited with unsuccessful code
+	ReasonTimeout          = "timeout"           // run was interrupted by timeout of the job
+	ReasonSkipped          = "skipped"           // run was dropped because previous run is still in progress
+	ReasonUnhealthy        = "unhealthy"         // run was skipped because container healthcheck isn't passed
+	ReasonUnexpectedOutput = "unexpected_output" // output doesn't match expect label
+	ReasonContainerMissing = "container_missing" // container was removed and there is no replacement
+	ReasonCancelled        = "cancelled"         // run was interrupted by scheduler shutdown
+	ReasonDockerError      = "docker_error"      // job couldn't be executed, ex. Docker API error
+)
+
 type Payload struct {
-	Project   string    `json:"project"`
-	Service   string    `json:"service"`
-	Container string    `json:"container"`
-	Schedule  string    `json:"schedule"`
-	Started   time.Time `json:"started"`
-	Finished  time.Time `json:"finished"`
-	Failed    bool      `json:"failed"`
-	Error     string    `json:"error,omitempty"`
+	Event          string    `json:"event,omitempty"` // lifecycle event of the scheduler, empty for job runs
+	Jobs           int       `json:"jobs,omitempty"`  // number of scheduled jobs, only for lifecycle events
+	RunID          string    `json:"run_id"`
+	Hostname       string    `json:"hostname"` // host of scheduler, see WithHostname
+	Project        string    `json:"project"`
+	Service        string    `json:"service"`
+	Job            string    `json:"job"`
+	Container      string    `json:"container"`
+	Schedule       string    `json:"schedule"`
+	Started        time.Time `json:"started"`
+	Finished       time.Time `json:"finished"`
+	Duration       float64   `json:"duration"` // duration of the run in seconds
+	Failed         bool      `json:"failed"`
+	Skipped        bool      `json:"skipped,omitempty"` // run was dropped because previous run is still in progress
+	Error          string    `json:"error,omitempty"`
+	Reason         string    `json:"reason,omitempty"`          // result of the run, see Reason constants; empty for lifecycle events
+	ExitCode       *int      `json:"exit_code,omitempty"`       // only if reason is nonzero_exit
+	Attempt        int       `json:"attempt,omitempty"`         // number of execution attempts, more than 1 if job was retried
+	Output         string    `json:"output,omitempty"`          // tail of command output, only for jobs with logs
+	OutputEncoding string    `json:"output_encoding,omitempty"` // base64 if output is encoded, empty for plain text
+	Delay          float64   `json:"delay,omitempty"`           // random delay (jitter) before the run in seconds
+	DryRun         bool      `json:"dry_run,omitempty"`         // job was not executed, see WithDryRun
 }
 
 type HTTPNotification struct {
 	URL           string        `long:"url" env:"URL" description:"URL to invoke"`
 	Retries       int           `long:"retries" env:"RETRIES" description:"Number of additional retries" default:"5"`
 	Interval      time.Duration `long:"interval" env:"INTERVAL" description:"Interval between attempts" default:"12s"`
+	BackoffFactor float64       `long:"backoff-factor" env:"BACKOFF_FACTOR" description:"Multiplier of interval after each attempt, 1 means fixed interval" default:"2"`
+	MaxInterval   time.Duration `long:"max-interval" env:"MAX_INTERVAL" description:"Maximum interval between attempts, no limit if not set" default:"5m"`
+	Jitter        float64       `long:"jitter" env:"JITTER" description:"Randomize interval by up to this fraction (ex: 0.2 is +-20%)"`
 	Method        string        `long:"method" env:"METHOD" description:"HTTP method" default:"POST"`
 	Timeout       time.Duration `long:"timeout" env:"TIMEOUT" description:"Request timeout" default:"30s"`
 	Authorization string        `long:"authorization" env:"AUTHORIZATION" description:"Authorization header value"`
+	Headers       []string      `long:"header" env:"HEADER" env-delim:"\n" description:"Extra header as 'Key: Value', can be repeated (newline-separated in env)"`
+	Template      string        `long:"template" env:"TEMPLATE" description:"Go template of request body, evaluated against payload (or list of payloads for batches)"`
 	UserAgent     string
+
+	template *template.Template
+}
+
+// defaultHTTPNotification returns copy of the first HTTP target or, if there is none, default settings.
+func defaultHTTPNotification(notifiers []Notifier) *HTTPNotification {
+	for _, notifier := range notifiers {
+		if ht, ok := notifier.(*HTTPNotification); ok {
+			cp := *ht
+			return &cp
+		}
+	}
+	return &HTTPNotification{
+		Retries:       5,
+		Interval:      12 * time.Second,
+		BackoffFactor: 2,
+		MaxInterval:   5 * time.Minute,
+		Method:        http.MethodPost,
+		Timeout:       30 * time.Second,
+	}
+}
+
+// Validate parses headers and body template, so configuration errors are detected at startup.
+func (ht *HTTPNotification) Validate() error {
+	if _, err := ht.headers(); err != nil {
+		return err
+	}
+	if ht.Template == "" {
+		return nil
+	}
+	tpl, err := parseBodyTemplate(ht.Template)
+	if err != nil {
+		return err
+	}
+	ht.template = tpl
+	return nil
+}
+
+func (ht *HTTPNotification) String() string {
+	return "HTTP"
 }
 
 func (ht *HTTPNotification) Notify(ctx context.Context, record *Payload) error {
+	return ht.send(ctx, record)
+}
+
+// NotifyBatch sends multiple records as single JSON array.
+func (ht *HTTPNotification) NotifyBatch(ctx context.Context, records []*Payload) error {
+	return ht.send(ctx, records)
+}
+
+func (ht *HTTPNotification) send(ctx context.Context, message any) error {
 	left := ht.Retries
+	interval := ht.Interval
 	for {
-		err := ht.notify(record)
+		err := ht.notify(message)
 		if err == nil {
-			log.Println("HTTP notification delivered")
 			return nil
 		}
 
 		if left <= 0 {
 			break
 		}
-		log.Println(left, "attempts left;", "notification failed:", err)
+		loggerFrom(ctx).Warn("notification attempt failed", "attempts_left", left, "error", err)
 
 		left--
 		select {
-		case <-time.After(ht.Interval):
+		case <-time.After(jitter(interval, ht.Jitter)):
 		case <-ctx.Done():
 			return ctx.Err()
 		}
+		interval = backoff(interval, ht.BackoffFactor, ht.MaxInterval)
 	}
 	return fmt.Errorf("all attempts failed")
 }
 
-func (ht *HTTPNotification) notify(message *Payload) error {
+func (ht *HTTPNotification) notify(message any) error {
 	ctx, cancel := context.WithTimeout(context.Background(), ht.Timeout)
 	defer cancel()
 
-	data, err := json.Marshal(message)
+	data, err := ht.body(message)
 	if err != nil {
-		return fmt.Errorf("marshal: %w", err)
+		return err
+	}
+	headers, err := ht.headers()
+	if err != nil {
+		return err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, ht.Method, ht.URL, bytes.NewReader(data))
@@ -75,6 +181,9 @@ func (ht *HTTPNotification) notify(message *Payload) error {
 	if ht.UserAgent != "" {
 		req.Header.Set("User-Agent", ht.UserAgent)
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("execute request: %w", err)
@@ -85,3 +194,80 @@ func (ht *HTTPNotification) notify(message *Payload) error {
 	}
 	return nil
 }
+
+func notifierName(notifier Notifier) string {
+	if named, ok := notifier.(fmt.Stringer); ok {
+		return named.String()
+	}
+	return fmt.Sprintf("%T", notifier)
+}
+
+// backoff returns next interval between attempts. Factor less or equal to 1 keeps interval fixed.
+func backoff(interval time.Duration, factor float64, maxInterval time.Duration) time.Duration {
+	if factor <= 1 {
+		return interval
+	}
+	next := time.Duration(float64(interval) * factor)
+	if maxInterval > 0 && next > maxInterval {
+		return maxInterval
+	}
+	return next
+}
+
+// jitter randomizes interval by up to the fraction in both directions.
+func jitter(interval time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + fraction*(2*rand.Float64()-1))) //nolint:gosec
+}
+
+// body of the request: JSON of the message or rendered template.
+func (ht *HTTPNotification) body(message any) ([]byte, error) {
+	if ht.Template == "" {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("marshal: %w", err)
+		}
+		return data, nil
+	}
+	tpl := ht.template
+	if tpl == nil {
+		var err error
+		tpl, err = parseBodyTemplate(ht.Template)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, message); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (ht *HTTPNotification) headers() (map[string]string, error) {
+	var ans = make(map[string]string, len(ht.Headers))
+	for _, header := range ht.Headers {
+		key, value, ok := strings.Cut(header, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("parse header %q: expected 'Key: Value'", header)
+		}
+		ans[key] = strings.TrimSpace(value)
+	}
+	return ans, nil
+}
+
+func parseBodyTemplate(text string) (*template.Template, error) {
+	tpl, err := template.New("").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tpl, nil
+}
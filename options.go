@@ -1,24 +1,348 @@
 package scheduler
 
-import "github.com/docker/docker/client"
+import (
+	"log/slog"
+	"time"
+)
 
 type Option func(scheduler *Scheduler)
 
-func WithDocker(dockerClient *client.Client) Option {
+// WithProject adds compose project to discover jobs in. Can be used several times to schedule jobs of many projects
+// by single scheduler. By default, project of the scheduler container is detected.
+func WithProject(composeProject string) Option {
 	return func(scheduler *Scheduler) {
-		scheduler.client = dockerClient
-		scheduler.borrowed = true
+		scheduler.projects = append(scheduler.projects, composeProject)
 	}
 }
 
-func WithProject(composeProject string) Option {
+// WithNotification adds HTTP notification target with JSON payload.
+func WithNotification(notification *HTTPNotification) Option {
 	return func(scheduler *Scheduler) {
-		scheduler.project = composeProject
+		scheduler.notifiers = append(scheduler.notifiers, notification)
 	}
 }
 
-func WithNotification(notification *HTTPNotification) Option {
+// WithNotificationDefaults sets settings (retries, timeout, headers, ...) of per-job HTTP notification targets
+// defined by labels. By default, settings of the first HTTP notification target are used.
+func WithNotificationDefaults(defaults *HTTPNotification) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifyBase = defaults
+	}
+}
+
+// WithOnParseError sets what to do with invalid jobs during discovery. Default is fail.
+func WithOnParseError(action ParseErrorAction) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.onParseError = action
+	}
+}
+
+// WithMinInterval rejects jobs which schedules fire more often than the interval, ex. `* * * * *` instead of
+// `0 * * * *`. Rejected jobs are handled according to WithOnParseError. Zero (default) means no limit.
+func WithMinInterval(interval time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.minInterval = interval
+	}
+}
+
+// WithProjectLabel sets label identifying project of containers, default is com.docker.compose.project.
+func WithProjectLabel(key string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.projectLabel = key
+	}
+}
+
+// WithServiceLabel sets label identifying service of containers, default is com.docker.compose.service.
+func WithServiceLabel(key string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.serviceLabel = key
+	}
+}
+
+// WithArtifacts sets directory where output of jobs with artifacts label is stored. Artifacts older than retention
+// are removed after each run. Zero retention means keep forever.
+func WithArtifacts(dir string, retention time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.artifacts = artifactStore{dir: dir, retention: retention}
+	}
+}
+
+// WithConnectRetry sets how many additional attempts to reach Docker daemon during Create and the interval between them.
+func WithConnectRetry(retries int, interval time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.connectRetries = retries
+		scheduler.connectInterval = interval
+	}
+}
+
+// WithAPIVersion pins Docker API version instead of negotiating it with daemon. Ignored if client provided by WithDocker.
+func WithAPIVersion(version string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.apiVersion = version
+	}
+}
+
+// WithDockerHost sets Docker daemon address (ex: tcp://10.0.0.2:2376) instead of DOCKER_HOST.
+// Ignored if client provided by WithDocker.
+func WithDockerHost(host string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.dockerHost = host
+	}
+}
+
+// WithDockerTLS enables TLS with ca.pem, cert.pem and key.pem from the directory, like DOCKER_CERT_PATH.
+// Server certificate is verified only if verify is set. Ignored if client provided by WithDocker.
+func WithDockerTLS(certPath string, verify bool) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.dockerCertPath = certPath
+		scheduler.dockerTLSVerify = verify
+	}
+}
+
+// WithHostname sets name of the host reported in notifications and used by WithSpreadByHost. Default is os.Hostname.
+func WithHostname(hostname string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.hostname = hostname
+	}
+}
+
+// WithSpreadByHost shifts fire time of each job by deterministic offset derived from hostname, service and job name,
+// so the same schedule fires at different time on different hosts. Offset is less than an hour and less
+// than the shortest interval of the schedule.
+func WithSpreadByHost() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.spreadByHost = true
+	}
+}
+
+// WithCrontab adds jobs from crontab-like file. Each line is <schedule> <service> [command...]. Jobs from the file
+// replace label-defined jobs of the same service.
+func WithCrontab(file string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.crontab = file
+	}
+}
+
+// WithNotificationBatch accumulates notifications and sends them as single JSON array every interval or once
+// batch reaches size (zero means no limit). If bypassFailures set, failed runs are notified immediately.
+func WithNotificationBatch(interval time.Duration, size int, bypassFailures bool) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.batch = newBatcher(interval, size, bypassFailures)
+	}
+}
+
+// WithMetrics serves Prometheus metrics on /metrics at the address while scheduler is running.
+func WithMetrics(addr string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.metricsAddr = addr
+		scheduler.metrics = newMetrics()
+	}
+}
+
+// WithWatch subscribes to Docker events and re-discovers tasks when labeled containers of the project are started or
+// destroyed, so redeployed services are picked up without restart.
+func WithWatch() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.watch = true
+	}
+}
+
+// WithRedis adds notification target which publishes payloads to Redis channel.
+func WithRedis(redis *RedisNotification) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifiers = append(scheduler.notifiers, redis)
+	}
+}
+
+// WithLeaderCheck enables leader-only jobs. Leadership is checked by HTTP endpoint (2xx means leader) or, if file is
+// set, by boolean content of the file. Result is cached for the given duration.
+func WithLeaderCheck(url, file string, cache time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.leader = &leaderCheck{url: url, file: file, cache: cache}
+	}
+}
+
+// WithTimezone sets default timezone (ex: Europe/Berlin) for schedules without explicit timezone label.
+func WithTimezone(zone string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.timezone = zone
+	}
+}
+
+// WithSeconds parses all schedules as six-field expressions with leading seconds field.
+func WithSeconds() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.seconds = true
+	}
+}
+
+// WithOutputEncoding defines how captured output with invalid UTF-8 is put into notifications. Default is sanitize.
+func WithOutputEncoding(encoding OutputEncoding) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.encoding = encoding
+	}
+}
+
+// WithMaxOutput limits size of command output included into notifications, only the tail is kept. Default is 8KiB.
+func WithMaxOutput(size int) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.maxOutput = size
+	}
+}
+
+// WithMaxLogBytes limits size of job output copied to scheduler logs, the rest is dropped with truncation marker.
+// Can be overridden by label. Zero (default) means no limit.
+func WithMaxLogBytes(size int) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.maxLogBytes = size
+	}
+}
+
+// WithLogDir writes output of jobs with logs label to <dir>/<service>-<job>.log instead of scheduler logs. File is
+// rotated once it exceeds maxSize (zero means no rotation), only one previous file is kept.
+func WithLogDir(dir string, maxSize int64) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.logFiles = &logFiles{dir: dir, maxSize: maxSize}
+	}
+}
+
+// WithForwardEnv passes variables of the scheduler environment with the prefix (ex: JOB_) to exec commands.
+// Variables defined by env label of the job take precedence.
+func WithForwardEnv(prefix string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.forwardEnv = prefix
+	}
+}
+
+// WithNotifyOn sets default policy which job runs are reported. Default is always.
+func WithNotifyOn(policy NotifyOn) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifyOn = policy
+	}
+}
+
+// WithJitter delays every scheduled run by random duration up to max, unless job sets own jitter by label.
+func WithJitter(max time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.jitter = max
+	}
+}
+
+// WithStopGrace sets how long to wait for service container to stop after timeout or shutdown before killing it.
+// Default is 10 seconds.
+func WithStopGrace(grace time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.stopGrace = grace
+	}
+}
+
+// WithStateFile persists last run time of jobs to the file. Required for jobs with catch-up.
+func WithStateFile(path string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.statePath = path
+	}
+}
+
+// WithHistory appends result of every run to JSONL file, keeping at most max records (unlimited if zero).
+func WithHistory(path string, max int) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.historyPath = path
+		scheduler.historyMax = max
+	}
+}
+
+// WithMaxConcurrent limits number of jobs running at the same time, other runs wait for free slot.
+// Zero means no limit.
+func WithMaxConcurrent(limit int) Option {
+	return func(scheduler *Scheduler) {
+		if limit > 0 {
+			scheduler.slots = make(chan struct{}, limit)
+		} else {
+			scheduler.slots = nil
+		}
+	}
+}
+
+// WithDryRun makes scheduler only log what would be executed instead of running jobs. Runs are reported as
+// successful and notifications are sent with DryRun flag.
+func WithDryRun() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.dryRun = true
+	}
+}
+
+// WithClock sets source of current time for run timestamps (Payload.Started and Payload.Finished), catch-up, uptime
+// checks and artifacts. Default is system clock.
+func WithClock(clock Clock) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.clock = clock
+	}
+}
+
+// WithNotifySkipped enables notifications about runs dropped because previous run of the job is still in progress,
+// or skipped because container is not healthy (see require-healthy label). Such notifications have Skipped flag and
+// are sent regardless of notify policy.
+func WithNotifySkipped() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifySkipped = true
+	}
+}
+
+// WithNotifyLifecycle sends notifications with Event started, once jobs are scheduled, and stopped, on shutdown.
+func WithNotifyLifecycle() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifyLifecycle = true
+	}
+}
+
+// WithNotifyQueue delivers notifications by background workers through queue of the given size, so jobs are not
+// delayed by slow targets. Jobs wait if the queue is full. Pending notifications are delivered before Run returns.
+// Zero size means notifications are delivered by job goroutine.
+func WithNotifyQueue(size int) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.queueSize = size
+	}
+}
+
+// WithBuildInfo sets build of the scheduler reported by /version endpoint of health and API servers.
+func WithBuildInfo(info BuildInfo) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.build = info
+	}
+}
+
+// WithNotifier adds notification targets. All targets are notified concurrently.
+func WithNotifier(notifiers ...Notifier) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.notifiers = append(scheduler.notifiers, notifiers...)
+	}
+}
+
+// WithRunOnce runs every job once in parallel and exits instead of running by schedule.
+func WithRunOnce() Option {
+	return func(scheduler *Scheduler) {
+		scheduler.runOnce = true
+	}
+}
+
+// WithAPI serves admin HTTP API on the address. If token is set, mutating endpoints require it as bearer token.
+func WithAPI(addr, token string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.apiAddr = addr
+		scheduler.apiToken = token
+	}
+}
+
+// WithHealth serves health endpoint /healthz on the address.
+func WithHealth(addr string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.healthAddr = addr
+	}
+}
+
+// WithLogger sets logger for the scheduler and notifications. Default is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
 	return func(scheduler *Scheduler) {
-		scheduler.notification = notification
+		scheduler.logger = logger
 	}
 }
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputEncoding defines how captured output with invalid UTF-8 (ex: binary data) is put into notification payload.
+type OutputEncoding string
+
+const defaultMaxOutput = 8 * 1024
+
+const (
+	OutputSanitize OutputEncoding = "sanitize" // replace invalid sequences by U+FFFD
+	OutputBase64   OutputEncoding = "base64"   // encode whole output by base64
+	OutputDrop     OutputEncoding = "drop"     // omit output
+)
+
+// encodeOutput returns output suitable for JSON and encoding of the result: empty for plain text or base64.
+// Valid UTF-8 output is always returned as-is.
+func encodeOutput(data []byte, encoding OutputEncoding) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	switch encoding {
+	case OutputBase64:
+		return base64.StdEncoding.EncodeToString(data), string(OutputBase64)
+	case OutputDrop:
+		return "", ""
+	default:
+		return strings.ToValidUTF8(string(data), "�"), ""
+	}
+}
+
+// tailBuffer keeps only last limit bytes written to it.
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	if limit <= 0 {
+		limit = defaultMaxOutput
+	}
+	return &tailBuffer{limit: limit}
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	if len(p) >= tb.limit {
+		tb.data = append(tb.data[:0], p[len(p)-tb.limit:]...)
+		return len(p), nil
+	}
+	if over := len(tb.data) + len(p) - tb.limit; over > 0 {
+		tb.data = append(tb.data[:0], tb.data[over:]...)
+	}
+	tb.data = append(tb.data, p...)
+	return len(p), nil
+}
+
+// Bytes returns kept output without leading partial UTF-8 sequence which may be left after truncation.
+func (tb *tailBuffer) Bytes() []byte {
+	data := tb.data
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.RuneStart(data[0]); i++ {
+		data = data[1:]
+	}
+	return data
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncodeOutput(t *testing.T) {
+	invalid := []byte("ok\xff\xfe")
+	cases := []struct {
+		encoding OutputEncoding
+		data     []byte
+		output   string
+		kind     string
+	}{
+		{OutputSanitize, invalid, "ok�", ""},
+		{OutputBase64, invalid, base64.StdEncoding.EncodeToString(invalid), string(OutputBase64)},
+		{OutputDrop, invalid, "", ""},
+		{"", invalid, "ok�", ""},
+		{OutputSanitize, []byte("ok €"), "ok €", ""},
+		{OutputBase64, []byte("ok €"), "ok €", ""},
+		{OutputDrop, []byte("ok €"), "ok €", ""},
+	}
+	for _, c := range cases {
+		output, kind := encodeOutput(c.data, c.encoding)
+		if output != c.output || kind != c.kind {
+			t.Errorf("%s of %q: expected %q (%q), got %q (%q)", c.encoding, c.data, c.output, c.kind, output, kind)
+		}
+	}
+}
+
+func TestTailBufferDropsRuneSplitAtBoundary(t *testing.T) {
+	tb := newTailBuffer(3)
+	_, _ = tb.Write([]byte("a€b")) // last 3 bytes start in the middle of €
+	for _, encoding := range []OutputEncoding{OutputSanitize, OutputBase64, OutputDrop} {
+		if output, kind := encodeOutput(tb.Bytes(), encoding); output != "b" || kind != "" {
+			t.Errorf("%s: expected %q, got %q (%q)", encoding, "b", output, kind)
+		}
+	}
+}
+
+func TestTailBufferKeepsInvalidInputAfterSplitRune(t *testing.T) {
+	tb := newTailBuffer(5)
+	_, _ = tb.Write([]byte("€"))
+	_, _ = tb.Write([]byte("\xff€")) // last 5 bytes start with the last byte of the first €
+	kept := []byte("\xff€")
+	if string(tb.Bytes()) != string(kept) {
+		t.Fatalf("expected %q, got %q", kept, tb.Bytes())
+	}
+	cases := map[OutputEncoding]string{
+		OutputSanitize: "�€",
+		OutputBase64:   base64.StdEncoding.EncodeToString(kept),
+		OutputDrop:     "",
+	}
+	for encoding, expected := range cases {
+		if output, _ := encodeOutput(tb.Bytes(), encoding); output != expected {
+			t.Errorf("%s: expected %q, got %q", encoding, expected, output)
+		}
+	}
+}
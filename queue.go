@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	notifyWorkers      = 4                // number of goroutines delivering queued notifications
+	notifyFlushTimeout = 30 * time.Second // how long pending notifications are delivered on shutdown
+)
+
+// notifyQueue delivers notifications in background, so finished jobs are not delayed by slow targets. If the queue is
+// full, sender is blocked until there is free space. Nil queue or closed queue doesn't accept notifications.
+type notifyQueue struct {
+	logger  *slog.Logger
+	lock    sync.RWMutex // held for reading while sending, so close doesn't race with senders
+	closed  bool
+	items   chan queuedNotification
+	workers sync.WaitGroup
+	ctx     context.Context // cancelled once flush timeout is reached
+	cancel  context.CancelFunc
+}
+
+type queuedNotification struct {
+	ctx     context.Context // not cancelled with the run, see notifyQueue.push
+	deliver func(ctx context.Context)
+}
+
+func newNotifyQueue(size int, logger *slog.Logger) *notifyQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &notifyQueue{logger: logger, items: make(chan queuedNotification, size), ctx: ctx, cancel: cancel}
+	q.workers.Add(notifyWorkers)
+	for i := 0; i < notifyWorkers; i++ {
+		go func() {
+			defer q.workers.Done()
+			for item := range q.items {
+				q.deliver(item)
+			}
+		}()
+	}
+	return q
+}
+
+// deliver notification with context which keeps values of the sender context, but is cancelled only by flush timeout.
+func (q *notifyQueue) deliver(item queuedNotification) {
+	ctx, cancel := context.WithCancel(item.ctx)
+	defer cancel()
+	stop := context.AfterFunc(q.ctx, cancel)
+	defer stop()
+	item.deliver(ctx)
+}
+
+// push adds delivery to the queue. Sender context is used without its cancellation, so notifications of runs
+// interrupted by shutdown are still delivered. Returns false if notification is not accepted and must be delivered
+// by caller.
+func (q *notifyQueue) push(ctx context.Context, deliver func(ctx context.Context)) bool {
+	if q == nil {
+		return false
+	}
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.closed {
+		return false
+	}
+	item := queuedNotification{ctx: context.WithoutCancel(ctx), deliver: deliver}
+	select {
+	case q.items <- item:
+		return true
+	default:
+	}
+	q.logger.Warn("notification queue is full, waiting for pending notifications", "size", cap(q.items))
+	q.items <- item
+	return true
+}
+
+// close stops accepting notifications and waits until pending notifications are delivered. Deliveries still in
+// progress after timeout are cancelled.
+func (q *notifyQueue) close(timeout time.Duration) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.lock.Unlock()
+	deadline := time.AfterFunc(timeout, func() {
+		q.logger.Warn("notifications are not delivered in time, cancelling pending notifications", "timeout", timeout.Seconds())
+		q.cancel()
+	})
+	q.workers.Wait()
+	deadline.Stop()
+	q.cancel()
+}
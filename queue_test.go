@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingNotifier holds the first notification until released and records whether delivery context was alive.
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+
+	lock   sync.Mutex
+	events map[string]error // context error by event
+}
+
+func (bn *blockingNotifier) Notify(ctx context.Context, record *Payload) error {
+	bn.once.Do(func() {
+		close(bn.started)
+		<-bn.release
+	})
+	bn.lock.Lock()
+	defer bn.lock.Unlock()
+	bn.events[record.Event] = ctx.Err()
+	return nil
+}
+
+func TestRunDeliversQueuedNotificationsOnShutdown(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	notifier := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{}), events: make(map[string]error)}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifyLifecycle(), WithNotifyQueue(10))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sc.Run(ctx) }()
+
+	select {
+	case <-notifier.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("started event is not delivered")
+	}
+	cancel() // started event is still pending in the queue
+	close(notifier.release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run is not finished")
+	}
+
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	for _, event := range []string{EventStarted, EventStopped} {
+		err, ok := notifier.events[event]
+		if !ok {
+			t.Errorf("%s event is not delivered", event)
+		} else if err != nil {
+			t.Errorf("%s event delivered with cancelled context: %v", event, err)
+		}
+	}
+}
+
+func TestNotifyQueueCancelsDeliveryAfterFlushTimeout(t *testing.T) {
+	q := newNotifyQueue(1, discardLogger())
+	var cancelled = make(chan error, 1)
+	q.push(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	})
+	q.close(10 * time.Millisecond)
+	if err := <-cancelled; err == nil {
+		t.Fatal("delivery is not cancelled")
+	}
+	if q.push(context.Background(), func(context.Context) {}) {
+		t.Fatal("closed queue accepted notification")
+	}
+}
+
+// silentBatchNotifier never answers batches, delivery ends only by cancellation. Single notifications are accepted.
+type silentBatchNotifier struct {
+	started chan struct{}
+	calls   chan struct{}
+}
+
+func (sn *silentBatchNotifier) Notify(_ context.Context, record *Payload) error {
+	if record.Event == EventStarted {
+		close(sn.started)
+	}
+	return nil
+}
+
+func (sn *silentBatchNotifier) NotifyBatch(ctx context.Context, _ []*Payload) error {
+	sn.calls <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunBoundsBatchFlushOnShutdown(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	notifier := &silentBatchNotifier{started: make(chan struct{}), calls: make(chan struct{}, 1)}
+	sc := newTestScheduler(t, fd, WithNotifier(notifier), WithNotifyLifecycle(), WithNotificationBatch(time.Hour, 0, false))
+	sc.flushTimeout = 100 * time.Millisecond
+	sc.batch.add(&Payload{Service: "web", Job: defaultJobName})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sc.Run(ctx) }()
+	select {
+	case <-notifier.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("started event is not delivered")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run is not finished within flush timeout")
+	}
+	select {
+	case <-notifier.calls:
+	default:
+		t.Fatal("pending batch is not flushed")
+	}
+}
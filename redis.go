@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultRedisPort = "6379"
+
+// RedisNotification publishes payloads as JSON to Redis channel. Minimal RESP client is used to avoid extra
+// dependencies; connection is established for each message.
+type RedisNotification struct {
+	URL     string        // redis://[[user]:password@]host[:port] or rediss:// for TLS
+	Channel string        // channel to publish to
+	Timeout time.Duration // connect and publish timeout
+}
+
+func (rn *RedisNotification) String() string {
+	return "Redis"
+}
+
+func (rn *RedisNotification) Notify(ctx context.Context, record *Payload) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if rn.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rn.Timeout)
+		defer cancel()
+	}
+	conn, err := rn.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	u, _ := url.Parse(rn.URL)
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if user := u.User.Username(); user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := redisCall(rw, args...); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if _, err := redisCall(rw, "PUBLISH", rn.Channel, string(data)); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	return nil
+}
+
+func (rn *RedisNotification) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(rn.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse URL: %w", err)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultRedisPort)
+	}
+	switch u.Scheme {
+	case "redis":
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "tcp", addr)
+	case "rediss":
+		dialer := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		return dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+}
+
+// redisCall sends command and reads simple reply (status, error, or integer).
+func redisCall(rw *bufio.ReadWriter, args ...string) (string, error) {
+	fmt.Fprintf(rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rw.Flush(); err != nil {
+		return "", err
+	}
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", errors.New(line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
@@ -2,263 +2,1573 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
-	"github.com/kballard/go-shellquote"
 	"github.com/robfig/cron/v3"
 )
 
 const (
 	composeProjectLabel = "com.docker.compose.project"
 	composeServiceLabel = "com.docker.compose.service"
-	schedulerLabel      = "net.reddec.scheduler.cron"
-	commandLabel        = "net.reddec.scheduler.exec"
-	logsLabel           = "net.reddec.scheduler.logs"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+	composeNumberLabel  = "com.docker.compose.container-number"
+	stopTimeout         = time.Minute
+	defaultStopGrace    = 10 * time.Second
+	eot                 = 0x04 // end of transmission (Ctrl-D), end of input for terminal
+	execPollInterval    = time.Second
+	standardFields      = 5
+	secondsFields       = 6
+)
+
+var (
+	standardParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	secondsParser  = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
 )
 
 func Create(ctx context.Context, options ...Option) (*Scheduler, error) {
-	sc := &Scheduler{}
+	sc := &Scheduler{resync: make(chan struct{}, 1)}
 	for _, opt := range options {
 		opt(sc)
 	}
+	if sc.logger == nil {
+		sc.logger = slog.Default()
+	}
+	if sc.projectLabel == "" {
+		sc.projectLabel = composeProjectLabel
+	}
+	if sc.serviceLabel == "" {
+		sc.serviceLabel = composeServiceLabel
+	}
+	if sc.clock == nil {
+		sc.clock = systemClock{}
+	}
+	if sc.stopGrace <= 0 {
+		sc.stopGrace = defaultStopGrace
+	}
+	if sc.flushTimeout <= 0 {
+		sc.flushTimeout = notifyFlushTimeout
+	}
+
+	if sc.notifyBase == nil {
+		sc.notifyBase = defaultHTTPNotification(sc.notifiers)
+	}
+	if err := sc.notifyBase.Validate(); err != nil {
+		return nil, fmt.Errorf("validate default HTTP notification: %w", err)
+	}
+
+	for _, notifier := range sc.notifiers {
+		if v, ok := notifier.(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("validate %s notification: %w", notifierName(notifier), err)
+			}
+		}
+	}
+
+	if sc.timezone != "" {
+		if _, err := time.LoadLocation(sc.timezone); err != nil {
+			return nil, fmt.Errorf("load timezone: %w", err)
+		}
+	}
+
+	if sc.statePath != "" {
+		st, err := loadState(sc.statePath)
+		if err != nil {
+			return nil, fmt.Errorf("load state %s: %w", sc.statePath, err)
+		}
+		sc.state = st
+	}
+
+	if sc.logFiles != nil {
+		if err := os.MkdirAll(sc.logFiles.dir, 0755); err != nil {
+			return nil, fmt.Errorf("create log dir: %w", err)
+		}
+	}
+
+	if sc.historyPath != "" {
+		h, err := openHistory(sc.historyPath, sc.historyMax)
+		if err != nil {
+			return nil, fmt.Errorf("open history %s: %w", sc.historyPath, err)
+		}
+		sc.history = h
+	}
 
-	if sc.client == nil {
-		dockerClient, err := client.NewClientWithOpts(client.FromEnv)
+	if sc.docker == nil {
+		docker, err := sc.newDocker()
 		if err != nil {
 			return nil, fmt.Errorf("create docker client: %w", err)
 		}
-		sc.client = dockerClient
+		sc.docker = docker
 		sc.borrowed = false
 	}
 
-	if sc.project == "" {
-		project, err := getComposeProject(ctx, sc.client)
+	if err := sc.waitDocker(ctx); err != nil {
+		_ = sc.Close()
+		return nil, fmt.Errorf("connect to docker: %w", err)
+	}
+
+	// should be done before any other call, otherwise calls may fail on older daemons
+	sc.docker.negotiate(ctx)
+
+	if err := sc.docker.checkVersion(ctx, sc.logger); err != nil {
+		_ = sc.Close()
+		return nil, fmt.Errorf("check docker version: %w", err)
+	}
+
+	if sc.hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			_ = sc.Close()
+			return nil, fmt.Errorf("get hostname: %w", err)
+		}
+		sc.hostname = hostname
+	}
+
+	if len(sc.projects) == 0 {
+		project, err := getComposeProject(ctx, sc.docker, sc.projectLabel)
 		if err != nil {
 			_ = sc.Close()
-			return nil, fmt.Errorf("get compose project: %w", err)
+			return nil, fmt.Errorf("get compose project: %w (set project explicitly if scheduler is not part of the project or Docker host is remote)", err)
 		}
-		sc.project = project
+		sc.projects = []string{project}
 	}
 	return sc, nil
 }
 
-type Task struct {
-	Service   string
-	Container string
-	Schedule  string
-	Command   []string
-	logging   bool
+type Scheduler struct {
+	projects    []string
+	docker      *dockerAPI
+	borrowed    bool
+	notifiers   []Notifier
+	notifyBase  *HTTPNotification // base of per-job HTTP targets
+	leader      *leaderCheck
+	timezone    string
+	seconds     bool
+	encoding    OutputEncoding
+	maxOutput   int
+	maxLogBytes int
+	notifyOn    NotifyOn
+	runOnce     bool
+	dryRun      bool
+	apiAddr     string
+	apiToken    string
+	logger      *slog.Logger
+	clock       Clock
+	jitter      time.Duration
+	stopGrace   time.Duration
+	statePath   string
+	state       *state
+	history     *history
+	historyPath string
+	historyMax  int
+	logFiles    *logFiles     // job output destination instead of scheduler logs, nil means scheduler logs
+	slots       chan struct{} // held by running jobs, nil means no limit
+	healthAddr  string
+	health      *health
+	artifacts   artifactStore
+
+	connectRetries  int
+	connectInterval time.Duration
+	apiVersion      string
+	dockerHost      string
+	dockerCertPath  string
+	dockerTLSVerify bool
+	spreadByHost    bool
+	hostname        string
+	crontab         string
+	batch           *batcher
+	metricsAddr     string
+	metrics         *metrics
+	watch           bool
+	projectLabel    string
+	serviceLabel    string
+	notifySkipped   bool
+	onParseError    ParseErrorAction
+	minInterval     time.Duration
+	flushTimeout    time.Duration
+	notifyLifecycle bool
+	forwardEnv      string
+	queueSize       int          // capacity of notification queue, zero means delivery by job goroutine
+	queue           *notifyQueue // started by Run
+	bootTime        time.Time    // start of Run, see initial-delay label
+	build           BuildInfo
+
+	lock   sync.Mutex
+	engine *cron.Cron
+	jobs   map[string]*job // by task key
+	paused atomic.Bool     // scheduled runs are skipped
+	resync chan struct{}   // requests to re-discover jobs, see Reload
 }
 
-type Scheduler struct {
-	project      string
-	client       *client.Client
-	borrowed     bool
-	notification *HTTPNotification
+func (sc *Scheduler) newDocker() (*dockerAPI, error) {
+	return newDockerAPI(sc.dockerHost, sc.dockerCertPath, sc.dockerTLSVerify, sc.apiVersion)
 }
 
 func (sc *Scheduler) Close() error {
 	if sc.borrowed {
 		return nil
 	}
-	return sc.client.Close()
+	return sc.docker.close()
+}
+
+// reconnectDocker replaces Docker client by new one created with the same options, if the new client reaches daemon.
+// Client provided by WithDocker is never replaced.
+func (sc *Scheduler) reconnectDocker(ctx context.Context) {
+	if sc.borrowed {
+		return
+	}
+	fresh, err := sc.newDocker()
+	if err != nil {
+		sc.logger.Error("recreate docker client failed", "error", err)
+		return
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	if err := fresh.ping(pingCtx); err != nil {
+		// daemon is down, not only connection of the current client, so keep it; negotiation would fail too
+		_ = fresh.close()
+		return
+	}
+	fresh.negotiate(pingCtx)
+	if err := sc.docker.replace(fresh); err != nil {
+		sc.logger.Warn("close previous docker client failed", "error", err)
+	}
+	sc.logger.Info("docker client recreated")
+}
+
+// waitDocker pings daemon until it becomes reachable or retries are exhausted.
+func (sc *Scheduler) waitDocker(ctx context.Context) error {
+	left := sc.connectRetries
+	for {
+		err := sc.docker.ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if left <= 0 {
+			return err
+		}
+		sc.logger.Warn("docker daemon is not reachable", "attempts_left", left, "error", err)
+
+		left--
+		select {
+		case <-time.After(sc.connectInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
+
 func (sc *Scheduler) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	sc.bootTime = sc.clock.Now()
+	if sc.queueSize > 0 {
+		sc.queue = newNotifyQueue(sc.queueSize, sc.logger)
+	}
+	defer sc.flushNotifications() // after background jobs, which may notify, are finished
+	var background sync.WaitGroup
+	defer background.Wait()
+	defer cancel()
+
+	if sc.healthAddr != "" {
+		sc.health = &health{}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", sc.serveHealth)
+		mux.HandleFunc("/version", sc.serveVersion)
+		wait, err := startHTTP(ctx, sc.logger, sc.healthAddr, mux)
+		if err != nil {
+			return fmt.Errorf("start health server: %w", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			wait()
+		}()
+	}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		sc.pingDocker(ctx)
+	}()
+
 	tasks, err := sc.listTasks(ctx)
 	if err != nil {
 		return fmt.Errorf("list tasks: %w", err)
 	}
 
-	engine := cron.New()
+	if !sc.runOnce {
+		sc.stopOnStart(ctx, tasks)
+	}
 
-	for _, t := range tasks {
-		log.Println("task for service", t.Service, "at", t.Schedule, "| logging:", t.logging)
-		running := new(int32)
-		t := t
-		_, err = engine.AddFunc(t.Schedule, func() {
-			sc.runJob(ctx, running, t)
-		})
+	sc.lock.Lock() // Status may be called concurrently
+	sc.engine = cron.New(cron.WithParser(sc.parser()))
+	sc.jobs = make(map[string]*job)
+	sc.lock.Unlock()
+	if err := sc.schedule(ctx, tasks); err != nil {
+		return err
+	}
+	sc.health.discovered(len(tasks), nil)
+
+	if sc.batch != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			sc.runBatcher(ctx)
+		}()
+	}
+
+	if sc.runOnce {
+		return sc.runAll(ctx)
+	}
+
+	if sc.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", sc.metrics)
+		wait, err := startHTTP(ctx, sc.logger, sc.metricsAddr, mux)
+		if err != nil {
+			return fmt.Errorf("start metrics server: %w", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			wait()
+		}()
+	}
+
+	if sc.watch {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			sc.watchEvents(ctx)
+		}()
+	}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		sc.reloadOnRequest(ctx)
+	}()
+
+	if sc.apiAddr != "" {
+		server := &api{ctx: ctx, sc: sc, token: sc.apiToken}
+		wait, err := startHTTP(ctx, sc.logger, sc.apiAddr, server.handler())
 		if err != nil {
-			return fmt.Errorf("add service %s: %w", t.Service, err)
+			return fmt.Errorf("start API server: %w", err)
 		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			wait()
+			server.runs.Wait()
+		}()
 	}
 
-	engine.Start()
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		sc.catchUp(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		sc.startup(ctx)
+	}()
+
+	sc.engine.Start()
+	sc.health.start()
+	if sc.notifyLifecycle {
+		sc.notifyEvent(ctx, EventStarted)
+	}
 	<-ctx.Done()
-	<-engine.Stop().Done()
+	<-sc.engine.Stop().Done()
+	if sc.notifyLifecycle {
+		sc.notifyEvent(context.Background(), EventStopped)
+	}
 
 	return nil
 }
 
-func (sc *Scheduler) runJob(ctx context.Context, running *int32, t Task) {
-	started := time.Now()
-	err := sc.runTask(ctx, running, t)
-	end := time.Now()
-	var errMessage string
+// Pause stops scheduled runs until Resume. Runs in progress are not interrupted, manual runs are not affected.
+func (sc *Scheduler) Pause() {
+	if sc.paused.CompareAndSwap(false, true) {
+		sc.logger.Info("scheduling paused")
+	}
+}
+
+// Resume scheduled runs after Pause. Runs missed while paused are not executed.
+func (sc *Scheduler) Resume() {
+	if sc.paused.CompareAndSwap(true, false) {
+		sc.logger.Info("scheduling resumed")
+	}
+}
+
+// Reload requests re-discovery of jobs without waiting for it. Jobs with unchanged schedule keep their state, including
+// runs in progress. Requests made while reload is in progress are merged into single reload.
+func (sc *Scheduler) Reload() {
+	select {
+	case sc.resync <- struct{}{}:
+	default:
+	}
+}
+
+// reloadOnRequest reloads jobs on each Reload call until context is done.
+func (sc *Scheduler) reloadOnRequest(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc.resync:
+			sc.logger.Info("reloading jobs by request")
+			if err := sc.reload(ctx); err != nil {
+				sc.logger.Error("reload jobs failed", "error", err)
+			}
+		}
+	}
+}
+
+// notifyEvent sends lifecycle event of the scheduler with number of scheduled jobs to all targets without batching.
+func (sc *Scheduler) notifyEvent(ctx context.Context, event string) {
+	sc.lock.Lock()
+	jobs := len(sc.jobs)
+	sc.lock.Unlock()
+	now := sc.clock.Now()
+	payload := &Payload{
+		Event:    event,
+		Jobs:     jobs,
+		Hostname: sc.hostname,
+		Project:  strings.Join(sc.projects, ","),
+		Started:  now,
+		Finished: now,
+	}
+	sc.notify(ctx, payload, sc.notifiers, false)
+}
+
+// stopOnStart stops running service containers of jobs with stop-on-start label, so containers started by compose up
+// are not executed outside of schedule.
+func (sc *Scheduler) stopOnStart(ctx context.Context, tasks []Task) {
+	var seen = make(map[string]bool)
+	for _, t := range tasks {
+		if !t.stopOnStart || seen[t.Container] {
+			continue
+		}
+		seen[t.Container] = true
+		logger := sc.logger.With("service", t.Service, "container", t.instance)
+		state, err := sc.docker.state(ctx, t.Container)
+		if err != nil {
+			logger.Error("get container state failed", "error", err)
+			continue
+		}
+		if !state.Running {
+			continue
+		}
+		logger.Info("stopping service started outside of schedule", "grace", sc.stopGrace.Seconds())
+		if err := sc.docker.stop(ctx, t.Container, sc.stopGrace); err != nil {
+			logger.Error("stop service failed", "error", err)
+		}
+	}
+}
+
+// catchUp runs once jobs with catch-up enabled, if their scheduled run was missed since the last run.
+func (sc *Scheduler) catchUp(ctx context.Context) {
+	now := sc.clock.Now()
+	var missed []*job
+	sc.lock.Lock()
+	for _, j := range sc.jobs {
+		t := j.current()
+		if !t.catchup || t.runsOnStart() {
+			continue
+		}
+		last, ok := sc.state.lastRun(t)
+		if !ok {
+			continue
+		}
+		if next := j.sched.Next(last); !next.IsZero() && !next.After(now) {
+			sc.logger.Info("scheduled run was missed, catching up", "service", t.Service, "job", t.Name, "missed", next)
+			missed = append(missed, j)
+		}
+	}
+	sc.lock.Unlock()
+	sc.runAfterWarmup(ctx, missed)
+}
+
+// runAll runs every job once in parallel and returns combined error of failed runs.
+func (sc *Scheduler) runAll(ctx context.Context) error {
+	sc.lock.Lock()
+	var jobs = make([]*job, 0, len(sc.jobs))
+	for _, j := range sc.jobs {
+		jobs = append(jobs, j)
+	}
+	sc.lock.Unlock()
+
+	return sc.runJobs(ctx, jobs)
+}
+
+// Trigger runs the job once immediately with the same policies and notifications as scheduled runs. Empty name
+// means default job. Empty project matches any project, but fails if the service exists in several projects.
+// If service has several containers, all of them must be requested explicitly by all flag.
+// Output of the job is copied to scheduler logs.
+func (sc *Scheduler) Trigger(ctx context.Context, project, service, name string, all bool) error {
+	if name == "" {
+		name = defaultJobName
+	}
+	tasks, err := sc.Tasks(ctx)
+	if err != nil {
+		return err
+	}
+	var matched []Task
+	for _, t := range tasks {
+		if t.Service == service && t.Name == name && (project == "" || t.Project == project) {
+			matched = append(matched, t)
+		}
+	}
+	if len(matched) == 0 {
+		return fmt.Errorf("job %s in service %s not found", name, service)
+	}
+	if err := checkProjects(service, matched); err != nil {
+		return err
+	}
+	var jobs = make([]*job, 0, len(matched))
+	for _, t := range matched {
+		t.Logging = true
+		jobs = append(jobs, newJob(t))
+	}
+	if len(jobs) > 1 && !all {
+		return fmt.Errorf("job %s in service %s has %d containers, use all flag to run on every container", name, service, len(jobs))
+	}
+	err = sc.runJobs(ctx, jobs)
+	if sc.batch != nil {
+		sc.flushBatch(ctx)
+	}
+	return err
+}
+
+// checkProjects fails with errAmbiguousService if tasks of the service belong to several projects.
+func checkProjects(service string, tasks []Task) error {
+	var seen = make(map[string]bool)
+	var projects []string
+	for _, t := range tasks {
+		if !seen[t.Project] {
+			seen[t.Project] = true
+			projects = append(projects, t.Project)
+		}
+	}
+	if len(projects) < 2 {
+		return nil
+	}
+	sort.Strings(projects)
+	return fmt.Errorf("%w: service %s found in projects %s", errAmbiguousService, service, strings.Join(projects, ", "))
+}
+
+// runJobs runs jobs in parallel and returns error of failed run or, if many failed, combined error.
+func (sc *Scheduler) runJobs(ctx context.Context, jobs []*job) error {
+	var lock sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			if err := sc.runJob(ctx, j, &run{id: newRunID()}); err != nil {
+				t := j.current()
+				lock.Lock()
+				errs = append(errs, fmt.Errorf("job %s in service %s: %w", t.Name, t.Service, err))
+				lock.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	var failed = make([]string, 0, len(errs))
+	for _, err := range errs {
+		failed = append(failed, err.Error())
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("%d of %d jobs failed: %s", len(failed), len(jobs), strings.Join(failed, "; "))
+}
+
+// parser of cron expressions: five fields by default or six fields (with seconds) if enabled, and @startup.
+func (sc *Scheduler) parser() cron.ScheduleParser {
+	if sc.seconds {
+		return startupParser{parser: secondsParser}
+	}
+	return startupParser{parser: standardParser}
+}
+
+func (sc *Scheduler) fields() int {
+	if sc.seconds {
+		return secondsFields
+	}
+	return standardFields
+}
+
+// Tasks returns jobs discovered from labels and crontab without scheduling them. All schedules are validated.
+func (sc *Scheduler) Tasks(ctx context.Context) ([]Task, error) {
+	tasks, err := sc.listTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// validateSchedules parses every schedule by the engine parser and reports all invalid ones at once.
+func (sc *Scheduler) validateSchedules(tasks []Task) error {
+	var invalid []string
+	for _, t := range tasks {
+		if err := sc.checkSchedule(t); err != nil {
+			invalid = append(invalid, fmt.Sprintf("job %s in service %s: %v", t.Name, t.Service, err))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d schedules are invalid: %s", len(invalid), len(tasks), strings.Join(invalid, "; "))
+}
+
+// checkSchedule parses schedule of the task and checks that it doesn't fire more often than minimal interval.
+func (sc *Scheduler) checkSchedule(t Task) error {
+	schedule, err := sc.parseSchedule(t)
 	if err != nil {
+		return fmt.Errorf("schedule %q: %w", t.Schedule, err)
+	}
+	if sc.minInterval <= 0 {
+		return nil
+	}
+	if interval := shortestInterval(schedule, sc.clock.Now()); interval > 0 && interval < sc.minInterval {
+		return fmt.Errorf("schedule %q fires every %v, less than minimal interval %v", t.Schedule, interval, sc.minInterval)
+	}
+	return nil
+}
+
+// NextRun returns next fire time of the task after the given time, including spread offset.
+func (sc *Scheduler) NextRun(t Task, after time.Time) (time.Time, error) {
+	schedule, err := sc.parseSchedule(t)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse schedule of job %s in service %s: %w", t.Name, t.Service, err)
+	}
+	return schedule.Next(after), nil
+}
+
+func (sc *Scheduler) parseSchedule(t Task) (cron.Schedule, error) {
+	schedule, err := sc.parser().Parse(t.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	if _, never := schedule.(neverSchedule); sc.spreadByHost && !never {
+		offset := spreadOffset(schedule, sc.hostname+"/"+t.Service+"/"+t.Name, sc.clock.Now())
+		schedule = spreadSchedule{schedule: schedule, offset: offset}
+	}
+	return schedule, nil
+}
+
+// runJob runs the job and reports result. Returned error is nil if run was skipped.
+func (sc *Scheduler) runJob(ctx context.Context, j *job, r *run) error {
+	t := j.current()
+	runID := r.id
+	r.logger = sc.logger.With("service", t.Service, "job", t.Name, "run_id", runID)
+	if sc.metrics != nil {
+		sc.metrics.started(t)
+	}
+	r.logger.Info("run started", "mode", t.Mode)
+	started := sc.clock.Now()
+	err := sc.runTask(ctx, j, r)
+	end := sc.clock.Now()
+	duration := end.Sub(started)
+	if sc.metrics != nil {
+		sc.metrics.finished(t, duration, resultOf(err))
+	}
+	record := historyRecord{RunID: runID, Project: t.Project, Service: t.Service, Job: t.Name, Started: started, Finished: end, Result: resultOf(err)}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := sc.history.add(record); err != nil {
+		r.logger.Error("write history failed", "error", err)
+	}
+	unhealthy := errors.Is(err, errUnhealthy) // notified as dropped runs, otherwise the same as other skips
+	if errors.Is(err, errSkipped) && !unhealthy {
+		r.logger.Info("run skipped", "result", resultSkipped, "duration", duration.Seconds(), "reason", err)
+		return nil
+	}
+	ret := err
+	if unhealthy {
+		ret = nil
+	}
+	if r.tries > 0 { // command was executed, not dropped by concurrency policy or dry run
+		if err := sc.state.save(t, started); err != nil {
+			r.logger.Error("save state failed", "error", err)
+		}
+	}
+	prev, hasPrev := j.lastResult()
+	if !errors.Is(err, errTaskRunning) && !unhealthy {
+		prev, hasPrev = j.setLastResult(result{started: started, finished: end, err: err})
+	}
+	overlap := errors.Is(err, errTaskRunning) || unhealthy
+	var errMessage string
+	switch {
+	case unhealthy:
 		errMessage = err.Error()
-		log.Println("service", t.Service, "failed after", end.Sub(started), "with error:", err)
-	} else {
-		log.Println("service", t.Service, "finished after", end.Sub(started), "successfully")
+		r.logger.Info("run skipped", "result", resultSkipped, "duration", duration.Seconds(), "reason", err)
+	case overlap:
+		errMessage = err.Error()
+		r.logger.Warn("run skipped", "result", resultSkipped, "duration", duration.Seconds(), "reason", err)
+	case err != nil:
+		errMessage = err.Error()
+		r.logger.Error("run failed", "result", resultFailure, "duration", duration.Seconds(), "error", err)
+	default:
+		r.logger.Info("run finished", "result", resultSuccess, "duration", duration.Seconds())
 	}
-	if sc.notification == nil {
-		return
+	if overlap && !sc.notifySkipped || !overlap && !sc.shouldNotify(t, err, prev, hasPrev) {
+		return ret
 	}
-	err = sc.notification.Notify(ctx, &Payload{
-		Project:   sc.project,
+	payload := &Payload{
+		RunID:     runID,
+		Hostname:  sc.hostname,
+		Project:   t.Project,
 		Service:   t.Service,
+		Job:       t.Name,
 		Container: t.Container,
 		Schedule:  t.Schedule,
 		Started:   started,
 		Finished:  end,
-		Failed:    err != nil,
+		Duration:  duration.Seconds(),
+		Failed:    err != nil && !overlap,
+		Skipped:   overlap,
 		Error:     errMessage,
+		Delay:     r.delay.Seconds(),
+		DryRun:    sc.dryRun,
+		Attempt:   r.tries,
+	}
+	payload.Reason, payload.ExitCode = reasonOf(err)
+	if r.output != nil {
+		payload.Output, payload.OutputEncoding = encodeOutput(r.output.Bytes(), sc.encoding)
+	}
+	sc.notify(ctx, payload, sc.taskNotifiers(t), t.notifyURL == "")
+	return ret
+}
+
+// taskNotifiers returns notification targets of the task: per-task HTTP target replaces global HTTP targets.
+func (sc *Scheduler) taskNotifiers(t Task) []Notifier {
+	if t.notifyURL == "" {
+		return sc.notifiers
+	}
+	target := *sc.notifyBase
+	target.URL = t.notifyURL
+	if t.notifyAuth != "" {
+		target.Authorization = t.notifyAuth
+	}
+	var ans = []Notifier{&target}
+	for _, notifier := range sc.notifiers {
+		if _, ok := notifier.(*HTTPNotification); !ok {
+			ans = append(ans, notifier)
+		}
+	}
+	return ans
+}
+
+// logLimit returns max bytes of job output copied to scheduler logs, zero means no limit.
+func (sc *Scheduler) logLimit(t Task) int {
+	if t.maxLogBytes > 0 {
+		return t.maxLogBytes
+	}
+	return sc.maxLogBytes
+}
+
+// outputLog returns destination of job output copied to logs: job log file if log dir is set, scheduler logs otherwise.
+func (sc *Scheduler) outputLog(r *run) io.WriteCloser {
+	if sc.logFiles != nil {
+		return sc.logFiles.writer(r, sc.clock)
+	}
+	return &lineLogger{logger: r.logger, limit: sc.logLimit(r.task)}
+}
+
+// delayRun sleeps random duration up to jitter of the job (or scheduler default) before scheduled run.
+func (sc *Scheduler) delayRun(ctx context.Context, j *job, r *run) error {
+	t := j.current()
+	jitter := t.jitter
+	if jitter == 0 {
+		jitter = sc.jitter
+	}
+	if jitter <= 0 {
+		return nil
+	}
+	r.delay = time.Duration(rand.Int63n(int64(jitter))) //nolint:gosec
+	sc.logger.Info("run delayed by jitter", "service", t.Service, "job", t.Name, "run_id", r.id, "delay", r.delay.Seconds())
+	timer := time.NewTimer(r.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shouldNotify applies notify policy of the task (or scheduler default) to the run result.
+func (sc *Scheduler) shouldNotify(t Task, err error, prev result, hasPrev bool) bool {
+	policy := t.notifyOn
+	if policy == "" {
+		policy = sc.notifyOn
+	}
+	switch policy {
+	case NotifyFailure:
+		return err != nil
+	case NotifyChange:
+		if !hasPrev {
+			return err != nil
+		}
+		return (err != nil) != (prev.err != nil)
+	default:
+		return true
+	}
+}
+
+// notify queues payload for delivery or, if there is no queue, delivers it immediately.
+func (sc *Scheduler) notify(ctx context.Context, payload *Payload, notifiers []Notifier, batchable bool) {
+	queued := sc.queue.push(ctx, func(ctx context.Context) {
+		sc.deliver(ctx, payload, notifiers, batchable)
 	})
+	if !queued {
+		sc.deliver(ctx, payload, notifiers, batchable)
+	}
+}
+
+// flushNotifications waits for queued notifications and sends pending batch, which queued notifications may have
+// added to. Together they are limited by flush timeout, so unavailable target doesn't block shutdown.
+func (sc *Scheduler) flushNotifications() {
+	deadline := time.Now().Add(sc.flushTimeout)
+	sc.queue.close(sc.flushTimeout)
+	if sc.batch != nil {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		sc.flushBatch(ctx)
+	}
+}
+
+// deliver payload to targets concurrently, so slow target doesn't delay others. Batch notifiers get payload with the
+// next batch if batching is enabled and payload is batchable.
+func (sc *Scheduler) deliver(ctx context.Context, payload *Payload, notifiers []Notifier, batchable bool) {
+	batched := batchable && sc.batch != nil && !(payload.Failed && sc.batch.bypassFailures)
+	result := resultSuccess
+	if payload.Failed {
+		result = resultFailure
+	}
+	var toBatch bool
+	var wg sync.WaitGroup
+	for _, notifier := range notifiers {
+		if _, ok := notifier.(BatchNotifier); ok && batched {
+			toBatch = true
+			continue
+		}
+		wg.Add(1)
+		go func(notifier Notifier) {
+			defer wg.Done()
+			logger := sc.logger.With("service", payload.Service, "job", payload.Job, "run_id", payload.RunID, "notifier", notifierName(notifier), "result", result)
+			if err := notifier.Notify(withLogger(ctx, logger), payload); err != nil {
+				logger.Error("notification failed", "error", err)
+			} else {
+				logger.Info("notification delivered")
+			}
+		}(notifier)
+	}
+	if toBatch {
+		sc.batch.add(payload)
+	}
+	wg.Wait()
+}
+
+func (sc *Scheduler) runTask(ctx context.Context, j *job, r *run) error {
+	var err error
+	tk := r.ticket
+	if tk == nil {
+		tk, err = j.reserve()
+		if err != nil {
+			return err
+		}
+	}
+	defer tk.release()
+
+	if err := sc.checkLeader(ctx, j.current()); err != nil {
+		return err
+	}
+	if err := tk.wait(ctx, r.logger); err != nil {
+		return err
+	}
+
+	r.task = j.current()
+	if r.task.Logging || r.task.expect != nil {
+		r.output = newTailBuffer(sc.maxOutput)
+	}
+	if sc.forwardEnv != "" && r.task.Mode == ModeExec {
+		r.env = append(r.env, forwardedEnv(sc.forwardEnv, os.Environ(), r.task.env)...)
+	}
+	r.env = append(r.env, r.task.env...)
+	if r.task.passStatus {
+		r.env = append(r.env, j.statusEnv()...)
+	}
+
+	if err := sc.checkUptime(ctx, r.task); err != nil {
+		return err
+	}
+
+	if err := sc.checkHealth(ctx, r); err != nil {
+		return err
+	}
+
+	if err := sc.checkRunning(ctx, r.task); err != nil {
+		return err
+	}
+
+	release, err := sc.acquireSlot(ctx, r)
 	if err != nil {
-		log.Println("notification for service", t.Service, "failed:", err)
-	} else {
-		log.Println("notification for service", t.Service, "succeeded")
+		return err
+	}
+	defer release()
+
+	if r.task.Timeout > 0 && r.task.Mode != ModeRestart { // timeout is stop grace in restart mode
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.task.Timeout)
+		defer cancel()
+	}
+
+	if sc.dryRun {
+		r.logger.Info("dry run, execution skipped", "mode", r.task.Mode, "command", r.task.Command)
+		return nil
+	}
+
+	err = sc.executeAttempts(ctx, j, r)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %v: %w", errTimeout, r.task.Timeout, err)
 	}
+	return err
 }
 
-func (sc *Scheduler) runTask(ctx context.Context, running *int32, task Task) error {
-	if !atomic.CompareAndSwapInt32(running, 0, 1) {
-		return fmt.Errorf("task is running")
+// executeAttempts executes the task and re-attempts failed execution according to retries of the task.
+func (sc *Scheduler) executeAttempts(ctx context.Context, j *job, r *run) error {
+	for {
+		r.tries++
+		if r.output != nil { // output and expect check belong to the current attempt only
+			r.output = newTailBuffer(sc.maxOutput)
+		}
+		err := sc.execute(ctx, r)
+		if isNotFound(err) && ctx.Err() == nil {
+			err = sc.executeRecreated(ctx, j, r)
+		}
+		left := r.task.retries - r.tries + 1
+		if err == nil || left <= 0 || ctx.Err() != nil {
+			return err
+		}
+		r.logger.Warn("run attempt failed, retrying",
+			"attempt", r.tries, "attempts_left", left, "interval", r.task.retryDelay.Seconds(), "error", err)
+		select {
+		case <-time.After(r.task.retryDelay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// executeRecreated finds current container of the service, which was recreated after discovery (ex: redeployed), and
+// executes the task once again in it. The job uses the new container for next runs.
+func (sc *Scheduler) executeRecreated(ctx context.Context, j *job, r *run) error {
+	containerID, err := sc.resolveContainer(ctx, r.task)
+	if err != nil {
+		return err
 	}
-	defer atomic.StoreInt32(running, 0)
+	r.logger.Warn("container no longer exists, using recreated container", "old_id", r.task.Container, "new_id", containerID)
+	j.relocate(r.task.Container, containerID)
+	r.task.Container = containerID
+	return sc.execute(ctx, r)
+}
 
-	if len(task.Command) == 0 {
-		log.Println("running service", task.Service)
-		return sc.runService(ctx, task)
+// locateContainer checks that container of the job still exists. If the container was recreated after discovery, the
+// job is switched to the new container.
+func (sc *Scheduler) locateContainer(ctx context.Context, j *job) error {
+	t := j.current()
+	_, err := sc.docker.state(ctx, t.Container)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("inspect service %s: %w", t.Service, err)
 	}
-	log.Println("executing service", task.Service, "with command", task.Command)
-	return sc.execService(ctx, task)
+	containerID, err := sc.resolveContainer(ctx, t)
+	if err != nil {
+		return err
+	}
+	sc.logger.Warn("container no longer exists, using recreated container", "service", t.Service, "job", t.Name, "old_id", t.Container, "new_id", containerID)
+	j.relocate(t.Container, containerID)
+	return nil
 }
 
-func (sc *Scheduler) execService(ctx context.Context, task Task) error {
-	if task.logging {
-		return sc.execAttachService(ctx, task)
+// resolveContainer returns ID of current container of the task: container with the same name or, if it was renamed,
+// the same replica number of the service.
+func (sc *Scheduler) resolveContainer(ctx context.Context, t Task) (string, error) {
+	list, err := sc.docker.list(ctx, sc.projectLabel+"="+t.Project, sc.serviceLabel+"="+t.Service)
+	if err != nil {
+		return "", fmt.Errorf("list containers of service %s: %w", t.Service, err)
+	}
+	for _, c := range list {
+		if c.Name == t.instance && c.ID != t.Container {
+			return c.ID, nil
+		}
+	}
+	for _, c := range list {
+		if replicaNumber(c.Labels) == t.replica && c.ID != t.Container && c.Labels[composeOneoffLabel] != "True" {
+			return c.ID, nil
+		}
+	}
+	return "", fmt.Errorf("service %s: %w", t.Service, errContainerMissing)
+}
+
+func (sc *Scheduler) execute(ctx context.Context, r *run) error {
+	switch r.task.Mode {
+	case ModeExec:
+		r.logger.Info("executing command", "command", r.task.Command)
+		return sc.execService(ctx, r)
+	case ModeCreate:
+		r.logger.Info("creating container")
+		return sc.createService(ctx, r)
+	case ModeRestart:
+		r.logger.Info("restarting service")
+		return sc.restartService(ctx, r)
+	case ModeSignal:
+		r.logger.Info("sending signal", "signal", r.task.signal)
+		if err := sc.docker.signal(ctx, r.task.Container, r.task.signal); err != nil {
+			return fmt.Errorf("send %s to service %s: %w", r.task.signal, r.task.Service, err)
+		}
+		return nil
+	default:
+		r.logger.Info("running service")
+		return sc.runService(ctx, r)
+	}
+}
+
+func (sc *Scheduler) execService(ctx context.Context, r *run) error {
+	if r.task.Logging || r.task.artifacts || r.task.stdin != "" || r.task.expect != nil {
+		return sc.execAttachService(ctx, r)
 	} else {
-		return sc.execStartService(ctx, task)
+		return sc.execStartService(ctx, r)
 	}
 }
 
-func (sc *Scheduler) execStartService(ctx context.Context, task Task) error {
-	execID, err := sc.client.ContainerExecCreate(ctx, task.Container, types.ExecConfig{
-		Cmd: task.Command,
-	})
+func (sc *Scheduler) execStartService(ctx context.Context, r *run) error {
+	task := r.task
+	execID, err := sc.docker.execCreate(ctx, task.Container, r.execSpec(false))
 	if err != nil {
 		return fmt.Errorf("create exec for %s: %w", task.Service, err)
 	}
 
-	err = sc.client.ContainerExecStart(ctx, execID.ID, types.ExecStartCheck{})
+	err = sc.docker.execStart(ctx, execID, task.tty)
 	if err != nil {
 		return fmt.Errorf("exec for %s: %w", task.Service, err)
 	}
-	return nil
+	return sc.waitExec(ctx, r, execID)
 }
 
-func (sc *Scheduler) execAttachService(ctx context.Context, task Task) error {
-	execID, err := sc.client.ContainerExecCreate(ctx, task.Container, types.ExecConfig{
-		Cmd:          task.Command,
-		AttachStderr: true,
-		AttachStdout: true,
-	})
+func (sc *Scheduler) execAttachService(ctx context.Context, r *run) error {
+	task := r.task
+	var input []byte
+	if task.stdin != "" {
+		data, err := task.input()
+		if err != nil {
+			return fmt.Errorf("read stdin for %s: %w", task.Service, err)
+		}
+		input = data
+	}
+
+	execID, err := sc.docker.execCreate(ctx, task.Container, r.execSpec(true))
 	if err != nil {
 		return fmt.Errorf("create exec for %s: %w", task.Service, err)
 	}
 
-	attach, err := sc.client.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	attach, err := sc.docker.execAttach(ctx, execID, task.tty)
 	if err != nil {
 		return fmt.Errorf("exec for %s: %w", task.Service, err)
 	}
 	defer attach.Close()
-	io.Copy(log.Writer(), attach.Reader)
 
-	inspect, err := sc.client.ContainerExecInspect(ctx, execID.ID)
-	if err != nil {
-		return fmt.Errorf("inspect exec for %s: %w", task.Service, err)
+	var output []io.Writer
+	if task.Logging {
+		lines := sc.outputLog(r)
+		defer lines.Close()
+		output = append(output, lines)
+	}
+	if r.output != nil {
+		output = append(output, r.output)
+	}
+	if task.artifacts {
+		artifact, err := sc.artifacts.create(task, sc.clock.Now())
+		if err != nil {
+			return fmt.Errorf("create artifact for %s: %w", task.Service, err)
+		}
+		defer sc.closeArtifact(r, artifact)
+		output = append(output, artifact)
+	}
+	// feed input concurrently with reading output, so command filling output buffer doesn't block the write
+	var written = make(chan error, 1)
+	if task.stdin != "" {
+		if task.tty {
+			input = append(input, eot) // terminal doesn't see closed stream as end of input
+		}
+		go func() {
+			_, err := attach.Write(input)
+			if closeErr := attach.CloseWrite(); err == nil {
+				err = closeErr
+			}
+			written <- err
+		}()
+	} else {
+		written <- nil
+	}
+	out := io.MultiWriter(output...)
+	var copied = make(chan error, 1)
+	go func() {
+		_, err := attach.WriteTo(out)
+		copied <- err
+	}()
+	select {
+	case err := <-copied:
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("read output failed", "error", err)
+		}
+	case <-ctx.Done():
+		// hijacked connection ignores context, so close it explicitly to interrupt copying
+		_ = attach.Close()
+		<-copied // reading fails once connection is closed, so copying never outlives the run
+		<-written
+		return sc.abandonExec(r, execID, ctx.Err())
+	}
+	select {
+	case err := <-written:
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("write stdin for %s: %w", task.Service, err)
+		}
+	case <-ctx.Done():
+		_ = attach.Close() // command closed output, but doesn't read input
+		<-written
+		return sc.abandonExec(r, execID, ctx.Err())
 	}
-	if inspect.ExitCode != 0 {
-		return fmt.Errorf("command returned non-zero code %d", inspect.ExitCode)
+	if ctx.Err() != nil { // output finished together with cancellation, don't report cancelled run as succeeded
+		return sc.abandonExec(r, execID, ctx.Err())
+	}
+
+	if err := sc.waitExec(ctx, r, execID); err != nil {
+		return err
+	}
+	if task.expect != nil && !task.expect.Match(r.output.Bytes()) {
+		return fmt.Errorf("%w %q", errUnexpectedOutput, task.expect.String())
 	}
 	return nil
 }
 
-func (sc *Scheduler) runService(ctx context.Context, task Task) error {
-	err := sc.client.ContainerStart(ctx, task.Container, types.ContainerStartOptions{})
+// waitExec polls exec state until command finished and checks exit code.
+func (sc *Scheduler) waitExec(ctx context.Context, r *run, execID string) error {
+	task := r.task
+	ticker := time.NewTicker(execPollInterval)
+	defer ticker.Stop()
+	for {
+		inspect, err := sc.docker.execInspect(ctx, execID)
+		if err != nil {
+			return fmt.Errorf("inspect exec for %s: %w", task.Service, err)
+		}
+		if !inspect.Running {
+			if !task.succeeded(inspect.ExitCode) {
+				return fmt.Errorf("command returned %w", &ExitError{Code: inspect.ExitCode})
+			}
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return sc.abandonExec(r, execID, ctx.Err())
+		}
+	}
+}
+
+// abandonExec stops waiting for exec command after timeout or shutdown. Docker can't kill exec process, so the
+// command may still be running in the container; it is logged with process ID on the host.
+func (sc *Scheduler) abandonExec(r *run, execID string, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	if inspect, inspectErr := sc.docker.execInspect(ctx, execID); inspectErr == nil && inspect.Running {
+		r.logger.Warn("command is still running in container, it is not waited anymore", "host_pid", inspect.Pid)
+	}
+	return fmt.Errorf("exec for %s interrupted: %w", r.task.Service, err)
+}
+
+func (sc *Scheduler) closeArtifact(r *run, artifact *os.File) {
+	if err := artifact.Close(); err != nil {
+		r.logger.Error("close artifact failed", "error", err)
+	}
+	if err := sc.artifacts.prune(r.task, sc.clock.Now()); err != nil {
+		r.logger.Error("prune artifacts failed", "error", err)
+	}
+}
+
+func (sc *Scheduler) runService(ctx context.Context, r *run) error {
+	task := r.task
+	err := sc.docker.start(ctx, task.Container)
 	if err != nil {
 		return fmt.Errorf("start service %s: %w", task.Service, err)
 	}
-	ok, failed := sc.client.ContainerWait(ctx, task.Container, container.WaitConditionNotRunning)
-	select {
-	case res := <-ok:
-		if res.Error != nil {
-			return fmt.Errorf("service %s: %s", task.Service, res.Error.Message)
-		}
-		if res.StatusCode != 0 {
-			return fmt.Errorf("service %s: status code %d", task.Service, res.StatusCode)
-		}
-	case err = <-failed:
+	code, err := sc.docker.wait(ctx, task.Container)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("service %s %s: %w", task.Service, sc.stopService(r), ctx.Err())
+	}
+	if err != nil {
 		return fmt.Errorf("wait for service %s: %w", task.Service, err)
 	}
+	sc.collectLogs(ctx, r, task.Container)
+	if !task.succeeded(int(code)) {
+		return fmt.Errorf("service %s: %w", task.Service, &ExitError{Code: int(code)})
+	}
 	return nil
 }
 
-func (sc *Scheduler) listTasks(ctx context.Context) ([]Task, error) {
-	list, err := sc.client.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg("label", composeProjectLabel+"="+sc.project),
-			filters.Arg("label", composeServiceLabel),
-			filters.Arg("label", schedulerLabel),
-		),
-		All: true,
-	})
+// createService runs throwaway container created from service container. Created container is removed in any case.
+func (sc *Scheduler) createService(ctx context.Context, r *run) error {
+	containerID, err := sc.docker.clone(ctx, r.task.Container, r.task.instance+"-run-"+r.id)
 	if err != nil {
-		return nil, fmt.Errorf("list container: %w", err)
+		return fmt.Errorf("create container for service %s: %w", r.task.Service, err)
+	}
+	defer sc.removeContainer(r, containerID)
+
+	if err := sc.docker.start(ctx, containerID); err != nil {
+		return fmt.Errorf("start container for service %s: %w", r.task.Service, err)
+	}
+	code, err := sc.docker.wait(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("wait for container of service %s: %w", r.task.Service, err)
+	}
+	sc.collectLogs(ctx, r, containerID)
+	if !r.task.succeeded(int(code)) {
+		return fmt.Errorf("service %s: %w", r.task.Service, &ExitError{Code: int(code)})
+	}
+	return nil
+}
+
+// restartService restarts service container. Timeout of the task is used as stop grace period.
+func (sc *Scheduler) restartService(ctx context.Context, r *run) error {
+	grace := r.task.Timeout
+	if grace <= 0 {
+		grace = sc.stopGrace
+	}
+	if err := sc.docker.restart(ctx, r.task.Container, grace); err != nil {
+		return fmt.Errorf("restart service %s: %w", r.task.Service, err)
+	}
+	return nil
+}
+
+// collectLogs copies output of finished container since its last start to scheduler logs and run output.
+func (sc *Scheduler) collectLogs(ctx context.Context, r *run, containerID string) {
+	if !r.task.Logging {
+		return
+	}
+	state, err := sc.docker.state(ctx, containerID)
+	if err != nil {
+		r.logger.Error("inspect container failed", "error", err)
+		return
+	}
+	lines := sc.outputLog(r)
+	defer lines.Close()
+	if err := sc.docker.logs(ctx, containerID, state.StartedAt, io.MultiWriter(lines, r.output)); err != nil {
+		r.logger.Error("get logs failed", "error", err)
+	}
+}
+
+// removeContainer removes container created for the run, even if job is cancelled.
+func (sc *Scheduler) removeContainer(r *run, containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	if err := sc.docker.remove(ctx, containerID); err != nil {
+		r.logger.Error("remove container failed", "container", containerID, "error", err)
+	}
+}
+
+// checkUptime skips run if target container started recently.
+func (sc *Scheduler) checkUptime(ctx context.Context, task Task) error {
+	if task.minUptime <= 0 {
+		return nil
+	}
+	state, err := sc.docker.state(ctx, task.Container)
+	if err != nil {
+		return fmt.Errorf("inspect service %s: %w", task.Service, err)
+	}
+	if !state.Running {
+		return fmt.Errorf("%w: container is not running", errSkipped)
+	}
+	if uptime := sc.clock.Now().Sub(state.StartedAt); uptime < task.minUptime {
+		return fmt.Errorf("%w: container is up for %v, less than %v", errSkipped, uptime.Truncate(time.Second), task.minUptime)
+	}
+	return nil
+}
+
+// checkRunning skips run if service container is already running, ex. started by another scheduler of the same
+// project deployed twice, which in-memory concurrency policy can't detect.
+func (sc *Scheduler) checkRunning(ctx context.Context, task Task) error {
+	if !task.skipRunning {
+		return nil
+	}
+	state, err := sc.docker.state(ctx, task.Container)
+	if err != nil {
+		return fmt.Errorf("inspect service %s: %w", task.Service, err)
+	}
+	if state.Running {
+		return fmt.Errorf("%w: container is already running (started by another scheduler or manually)", errSkipped)
+	}
+	return nil
+}
+
+// acquireSlot waits until number of running jobs is below global limit. Returned function releases the slot.
+func (sc *Scheduler) acquireSlot(ctx context.Context, r *run) (func(), error) {
+	if sc.slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-sc.slots }
+	select {
+	case sc.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	r.logger.Info("too many jobs are running, waiting for free slot", "limit", cap(sc.slots))
+	select {
+	case sc.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// checkHealth skips run if job requires healthy container, but container is not healthy yet.
+func (sc *Scheduler) checkHealth(ctx context.Context, r *run) error {
+	if !r.task.healthy {
+		return nil
+	}
+	state, err := sc.docker.state(ctx, r.task.Container)
+	if err != nil {
+		return fmt.Errorf("inspect service %s: %w", r.task.Service, err)
+	}
+	switch state.Health {
+	case "healthy":
+		return nil
+	case "":
+		r.logger.Warn("container has no healthcheck, require-healthy is ignored")
+		return nil
+	default:
+		return fmt.Errorf("%w: %w (%s)", errSkipped, errUnhealthy, state.Health)
+	}
+}
+
+// stopService stops container of the service after job is cancelled or timed out. Container is killed if it is still
+// running after grace period. Returns how container was terminated.
+func (sc *Scheduler) stopService(r *run) string {
+	ctx, cancel := context.WithTimeout(context.Background(), sc.stopGrace+stopTimeout)
+	defer cancel()
+	r.logger.Info("stopping service", "grace", sc.stopGrace.Seconds())
+	if err := sc.docker.stop(ctx, r.task.Container, sc.stopGrace); err != nil {
+		r.logger.Error("stop service failed", "error", err)
+	}
+	state, err := sc.docker.state(ctx, r.task.Container)
+	if err == nil && !state.Running {
+		return "stopped"
+	}
+	r.logger.Warn("service is still running, killing")
+	if err := sc.docker.kill(ctx, r.task.Container); err != nil {
+		r.logger.Error("kill service failed", "error", err)
+		return "not stopped"
+	}
+	return "killed"
+}
+
+// listTasks discovers jobs of the project ordered by service, job name and container ID.
+func (sc *Scheduler) listTasks(ctx context.Context) ([]Task, error) {
+	var list []containerSummary
+	for _, project := range sc.projects {
+		containers, err := sc.docker.list(ctx,
+			sc.projectLabel+"="+project,
+			sc.serviceLabel,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("list container of project %s: %w", project, err)
+		}
+		list = append(list, containers...)
+	}
+	var skipped int
+	// invalid job fails discovery or, if configured, is logged and skipped
+	invalid := func(err error) error {
+		if sc.onParseError != ParseErrorSkip {
+			return err
+		}
+		sc.logger.Error("invalid job skipped", "error", err)
+		skipped++
+		return nil
 	}
 	var ans = make([]Task, 0, len(list))
 	for _, c := range list {
-		service := c.Labels[composeServiceLabel]
-		var args []string
-		if v := c.Labels[commandLabel]; v != "" {
-			cmd, err := shellquote.Split(v)
-			if err != nil {
-				return nil, fmt.Errorf("parse command in service %s: %w", service, err)
+		service := c.Labels[sc.serviceLabel]
+		for _, key := range unknownLabels(c.Labels) {
+			sc.logger.Warn("unknown scheduler label, ignored", "service", service, "container", c.Name, "label", key)
+		}
+		jobs, err := declaredJobs(c.Labels)
+		if err != nil {
+			if err := invalid(fmt.Errorf("parse jobs in service %s: %w", service, err)); err != nil {
+				return nil, err
 			}
-			args = cmd
+			continue
 		}
+		for _, jl := range jobs {
+			if jl.bool(disabledKey) {
+				sc.logger.Info("job disabled, skipped", "service", service, "job", jl.name)
+				continue
+			}
+			task, err := parseTask(c, service, jl)
+			task.Project = c.Labels[sc.projectLabel]
+			switch {
+			case err != nil:
+				err = fmt.Errorf("parse job %s in service %s: %w", jl.name, service, err)
+			case task.leaderOnly && sc.leader == nil:
+				err = fmt.Errorf("job %s in service %s is leader-only, but leader check is not configured", jl.name, service)
+			case task.catchup && sc.state == nil:
+				err = fmt.Errorf("job %s in service %s has catch-up, but state file is not configured", jl.name, service)
+			default:
+				ans = append(ans, task)
+				continue
+			}
+			if err := invalid(err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sortTasks(ans)
+	ans, err := sc.pickReplicas(ans)
+	if err != nil {
+		return nil, err
+	}
 
-		isLoggingEnabled, err := strconv.ParseBool(c.Labels[logsLabel])
+	if sc.crontab != "" {
+		ans, err = sc.mergeCrontab(ans, list)
 		if err != nil {
-			isLoggingEnabled = false
+			return nil, err
 		}
-
-		ans = append(ans, Task{
-			Container: c.ID,
-			Schedule:  c.Labels[schedulerLabel],
-			Service:   service,
-			Command:   args,
-			logging:   isLoggingEnabled,
-		})
 	}
+	for i := range ans {
+		ans[i].Schedule = withTimezone(ans[i].Schedule, sc.timezone)
+	}
+	sortTasks(ans)
+	if sc.onParseError == ParseErrorSkip {
+		valid := ans[:0]
+		for _, t := range ans {
+			if err := sc.checkSchedule(t); err != nil {
+				_ = invalid(fmt.Errorf("job %s in service %s: %w", t.Name, t.Service, err))
+				continue
+			}
+			valid = append(valid, t)
+		}
+		ans = valid
+	}
+	if err := sc.validateSchedules(ans); err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		sc.logger.Warn("some jobs are invalid and skipped", "skipped", skipped, "valid", len(ans))
+	}
+	return ans, nil
+}
 
+// pickReplicas keeps single replica (lowest container number, then name) of jobs with replicas policy one.
+func (sc *Scheduler) pickReplicas(tasks []Task) ([]Task, error) {
+	var policies = make(map[string]string) // by project/service/job
+	var chosen = make(map[string]int)      // index in ans by project/service/job
+	var count = make(map[string]int)
+	var ans = make([]Task, 0, len(tasks))
+	for _, t := range tasks {
+		key := t.Project + "/" + t.Service + "/" + t.Name
+		if policy, ok := policies[key]; ok && policy != t.replicas {
+			return nil, fmt.Errorf("replicas of job %s in service %s have different replicas policy", t.Name, t.Service)
+		}
+		policies[key] = t.replicas
+		if t.replicas != replicasOne {
+			ans = append(ans, t)
+			continue
+		}
+		count[key]++
+		i, ok := chosen[key]
+		if !ok {
+			chosen[key] = len(ans)
+			ans = append(ans, t)
+			continue
+		}
+		if t.replica < ans[i].replica || (t.replica == ans[i].replica && t.instance < ans[i].instance) {
+			ans[i] = t
+		}
+	}
+	for _, t := range ans {
+		if n := count[t.Project+"/"+t.Service+"/"+t.Name]; t.replicas == replicasOne && n > 1 {
+			sc.logger.Info("replica chosen", "service", t.Service, "job", t.Name, "container", t.instance, "replicas", n)
+		}
+	}
 	return ans, nil
 }
 
+// mergeCrontab adds tasks from crontab file. Crontab entries replace label-defined jobs of the same service.
+func (sc *Scheduler) mergeCrontab(tasks []Task, containers []containerSummary) ([]Task, error) {
+	entries, err := readCrontab(sc.crontab, sc.parser(), sc.fields())
+	if err != nil {
+		return nil, fmt.Errorf("read crontab %s: %w", sc.crontab, err)
+	}
+	var overridden = make(map[string]bool)
+	var fromCrontab []Task
+	for _, entry := range entries {
+		overridden[entry.service] = true
+		var found bool
+		for _, c := range containers {
+			// one-off containers (compose run, clones of create mode) are transient and must not get jobs
+			if c.Labels[sc.serviceLabel] != entry.service || c.Labels[composeOneoffLabel] == "True" {
+				continue
+			}
+			found = true
+			fromCrontab = append(fromCrontab, Task{
+				Name:        entry.name(),
+				Mode:        defaultMode(entry.command),
+				Concurrency: ConcurrencyForbid,
+				Project:     c.Labels[sc.projectLabel],
+				Service:     entry.service,
+				Container:   c.ID,
+				instance:    c.Name,
+				Schedule:    entry.schedule,
+				Command:     entry.command,
+			})
+		}
+		if !found {
+			return nil, fmt.Errorf("crontab %s line %d: service %s not found", sc.crontab, entry.line, entry.service)
+		}
+	}
+	var ans = make([]Task, 0, len(tasks)+len(fromCrontab))
+	for _, t := range tasks {
+		if overridden[t.Service] {
+			sc.logger.Info("job overridden by crontab", "service", t.Service, "job", t.Name)
+			continue
+		}
+		ans = append(ans, t)
+	}
+	return append(ans, fromCrontab...), nil
+}
+
 func containerID() (string, error) {
 	const path = `/proc/1/cpuset`
 	data, err := os.ReadFile(path)
@@ -273,7 +1583,7 @@ func containerID() (string, error) {
 	return id, nil
 }
 
-func getComposeProject(ctx context.Context, dockerClient *client.Client) (string, error) {
+func getComposeProject(ctx context.Context, docker *dockerAPI, projectLabel string) (string, error) {
 	var cID string
 	for _, lookup := range containerIDLookup {
 		v, err := lookup()
@@ -287,13 +1597,13 @@ func getComposeProject(ctx context.Context, dockerClient *client.Client) (string
 		return "", fmt.Errorf("failed detect self container ID")
 	}
 
-	info, err := dockerClient.ContainerInspect(ctx, cID)
+	labels, err := docker.labels(ctx, cID)
 	if err != nil {
 		return "", fmt.Errorf("inspect self container: %w", err)
 	}
-	project, ok := info.Config.Labels[composeProjectLabel]
+	project, ok := labels[projectLabel]
 	if !ok {
-		return "", fmt.Errorf("compose label not found - probably container is not part of compose")
+		return "", fmt.Errorf("label %s not found - probably container is not part of compose", projectLabel)
 	}
 	return project, nil
 }
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SlackNotification posts human-readable messages to Slack incoming webhook.
+type SlackNotification struct {
+	URL      string
+	Retries  int
+	Interval time.Duration
+	Timeout  time.Duration
+	// BackoffFactor, MaxInterval and Jitter are the same as for HTTPNotification.
+	BackoffFactor float64
+	MaxInterval   time.Duration
+	Jitter        float64
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func (sn *SlackNotification) String() string {
+	return "Slack"
+}
+
+func (sn *SlackNotification) Notify(ctx context.Context, record *Payload) error {
+	return sn.http().send(ctx, slackMessage{Text: slackText(record)})
+}
+
+// NotifyBatch sends multiple records as single message.
+func (sn *SlackNotification) NotifyBatch(ctx context.Context, records []*Payload) error {
+	var lines = make([]string, 0, len(records))
+	for _, record := range records {
+		lines = append(lines, slackText(record))
+	}
+	return sn.http().send(ctx, slackMessage{Text: strings.Join(lines, "\n")})
+}
+
+func (sn *SlackNotification) http() *HTTPNotification {
+	return &HTTPNotification{
+		URL:           sn.URL,
+		Retries:       sn.Retries,
+		Interval:      sn.Interval,
+		BackoffFactor: sn.BackoffFactor,
+		MaxInterval:   sn.MaxInterval,
+		Jitter:        sn.Jitter,
+		Method:        http.MethodPost,
+		Timeout:       sn.Timeout,
+	}
+}
+
+func slackText(record *Payload) string {
+	if record.Event != "" {
+		return fmt.Sprintf(":information_source: %s: scheduler %s on %s with %d jobs", record.Project, record.Event, record.Hostname, record.Jobs)
+	}
+	duration := record.Finished.Sub(record.Started).Truncate(time.Millisecond)
+	var text string
+	switch {
+	case record.Failed:
+		text = fmt.Sprintf(":x: %s: service `%s` job `%s` failed after %v: %s", record.Project, record.Service, record.Job, duration, record.Error)
+	case record.Skipped:
+		text = fmt.Sprintf(":fast_forward: %s: service `%s` job `%s` skipped: %s", record.Project, record.Service, record.Job, record.Error)
+	default:
+		text = fmt.Sprintf(":white_check_mark: %s: service `%s` job `%s` finished after %v", record.Project, record.Service, record.Job, duration)
+	}
+	if record.Output != "" && record.OutputEncoding == "" {
+		text += "\n```\n" + record.Output + "\n```"
+	}
+	return text
+}
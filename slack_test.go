@@ -0,0 +1,16 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlackTextOfSkippedRun(t *testing.T) {
+	text := slackText(&Payload{Project: "app", Service: "db", Job: "backup", Skipped: true, Error: "task is running"})
+	if !strings.HasPrefix(text, ":fast_forward:") || !strings.Contains(text, "skipped: task is running") {
+		t.Fatalf("unexpected text: %s", text)
+	}
+	if strings.Contains(text, "finished") {
+		t.Fatalf("skipped run is shown as finished: %s", text)
+	}
+}
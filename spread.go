@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	maxSpread     = time.Hour
+	spreadSamples = 16
+)
+
+// spreadSchedule shifts every fire time of the schedule by constant offset.
+type spreadSchedule struct {
+	schedule cron.Schedule
+	offset   time.Duration
+}
+
+func (ss spreadSchedule) Next(t time.Time) time.Time {
+	return ss.schedule.Next(t.Add(-ss.offset)).Add(ss.offset)
+}
+
+// shortestInterval returns the shortest gap between next fires of the schedule, sampled from now. Zero if schedule
+// fires less than twice.
+func shortestInterval(schedule cron.Schedule, now time.Time) time.Duration {
+	var ans time.Duration
+	prev := schedule.Next(now)
+	for i := 0; i < spreadSamples && !prev.IsZero(); i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); ans == 0 || gap < ans {
+			ans = gap
+		}
+		prev = next
+	}
+	return ans
+}
+
+// spreadOffset returns deterministic offset for the key. Offset is always less than the shortest interval between
+// fires of the schedule (so runs never skipped or merged) and less than maxSpread.
+func spreadOffset(schedule cron.Schedule, key string, now time.Time) time.Duration {
+	window := maxSpread
+	if interval := shortestInterval(schedule, now); interval > 0 && interval < window {
+		window = interval
+	}
+	slots := uint64(window / time.Second)
+	if slots == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return time.Duration(h.Sum64()%slots) * time.Second
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// startupDescriptor is schedule of jobs which run only once when scheduler starts.
+const startupDescriptor = "@startup"
+
+// startupParser extends cron parser by @startup descriptor.
+type startupParser struct {
+	parser cron.ScheduleParser
+}
+
+func (sp startupParser) Parse(spec string) (cron.Schedule, error) {
+	if spec == startupDescriptor {
+		return neverSchedule{}, nil
+	}
+	return sp.parser.Parse(spec)
+}
+
+// neverSchedule never fires. Zero time is treated by cron engine as never.
+type neverSchedule struct{}
+
+func (neverSchedule) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+// startup runs once jobs with @startup schedule or on-start label.
+func (sc *Scheduler) startup(ctx context.Context) {
+	var jobs []*job
+	sc.lock.Lock()
+	for _, j := range sc.jobs {
+		if t := j.current(); t.runsOnStart() {
+			sc.logger.Info("running job on start", "service", t.Service, "job", t.Name)
+			jobs = append(jobs, j)
+		}
+	}
+	sc.lock.Unlock()
+	sc.runAfterWarmup(ctx, jobs)
+}
+
+// warmupLeft returns how long runs of the task are still suppressed after scheduler start by initial-delay label.
+func (sc *Scheduler) warmupLeft(t Task) time.Duration {
+	if t.warmup <= 0 {
+		return 0
+	}
+	return max(t.warmup-sc.clock.Now().Sub(sc.bootTime), 0)
+}
+
+// runAfterWarmup runs jobs in parallel, each once its initial delay after scheduler start has passed.
+func (sc *Scheduler) runAfterWarmup(ctx context.Context, jobs []*job) {
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			if left := sc.warmupLeft(j.current()); left > 0 {
+				t := j.current()
+				sc.logger.Info("run delayed until initial delay passed", "service", t.Service, "job", t.Name, "delay", left.Seconds())
+				select {
+				case <-time.After(left):
+				case <-ctx.Done():
+					return
+				}
+			}
+			_ = sc.runJobs(ctx, []*job{j})
+		}(j)
+	}
+	wg.Wait()
+}
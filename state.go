@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// state persists last run time of jobs between restarts, so runs missed while scheduler was offline can be caught up.
+// Methods are safe on nil state.
+type state struct {
+	path string
+	lock sync.Mutex
+	last map[string]time.Time // by task key
+}
+
+func loadState(path string) (*state, error) {
+	st := &state{path: path, last: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &st.last); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return st, nil
+}
+
+func (st *state) lastRun(t Task) (time.Time, bool) {
+	if st == nil {
+		return time.Time{}, false
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	at, ok := st.last[t.key()]
+	return at, ok
+}
+
+// save last run time of the task. File is replaced atomically.
+func (st *state) save(t Task, at time.Time) error {
+	if st == nil {
+		return nil
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.last[t.key()] = at
+	data, err := json.MarshalIndent(st.last, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), filepath.Base(st.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	return os.Rename(tmp.Name(), st.path)
+}
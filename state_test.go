@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateSavedOnlyForExecutedRuns(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		dryRun bool
+		busy   bool // previous run is still in progress
+		saved  bool
+	}{
+		{name: "executed", saved: true},
+		{name: "dry run", dryRun: true},
+		{name: "dropped", busy: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+			options := []Option{WithStateFile(filepath.Join(t.TempDir(), "state.json"))}
+			if c.dryRun {
+				options = append(options, WithDryRun())
+			}
+			sc := newTestScheduler(t, fd, options...)
+			tasks, err := sc.Tasks(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := newJob(tasks[0])
+			if c.busy {
+				if _, err := j.reserve(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_ = sc.runJob(context.Background(), j, &run{id: newRunID()})
+			if _, saved := sc.state.lastRun(tasks[0]); saved != c.saved {
+				t.Fatalf("expected saved=%v, got %v", c.saved, saved)
+			}
+		})
+	}
+}
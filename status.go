@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// JobStatus is state of scheduled job.
+type JobStatus struct {
+	Project   string     `json:"project"`
+	Service   string     `json:"service"`
+	Job       string     `json:"job"`
+	Container string     `json:"container"`
+	Schedule  string     `json:"schedule"`
+	Mode      Mode       `json:"mode"`
+	Next      time.Time  `json:"next"`           // next fire time, zero if job is not scheduled (ex: @startup)
+	Last      *RunStatus `json:"last,omitempty"` // last executed run since scheduler start, nil if job never ran
+}
+
+// RunStatus is result of executed run. Skipped runs are not tracked.
+type RunStatus struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Result   string    `json:"result"` // success or failure
+	Failed   bool      `json:"failed"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Status returns state of scheduled jobs ordered by service, job and container. Empty until Run schedules jobs.
+func (sc *Scheduler) Status() []JobStatus {
+	sc.lock.Lock()
+	var list = make([]JobStatus, 0, len(sc.jobs))
+	for _, j := range sc.jobs {
+		t := j.current()
+		status := JobStatus{
+			Project:   t.Project,
+			Service:   t.Service,
+			Job:       t.Name,
+			Container: t.Container,
+			Schedule:  t.Schedule,
+			Mode:      t.Mode,
+			Next:      sc.engine.Entry(j.entry).Next,
+		}
+		if status.Next.IsZero() {
+			// engine computes fire time only once started
+			status.Next = j.sched.Next(sc.clock.Now())
+		}
+		if last, ok := j.lastResult(); ok {
+			status.Last = &RunStatus{
+				Started:  last.started,
+				Finished: last.finished,
+				Result:   resultOf(last.err),
+				Failed:   last.err != nil,
+			}
+			if last.err != nil {
+				status.Last.Error = last.err.Error()
+			}
+		}
+		list = append(list, status)
+	}
+	sc.lock.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Project != list[j].Project {
+			return list[i].Project < list[j].Project
+		}
+		if list[i].Service != list[j].Service {
+			return list[i].Service < list[j].Service
+		}
+		if list[i].Job != list[j].Job {
+			return list[i].Job < list[j].Job
+		}
+		return list[i].Container < list[j].Container
+	})
+	return list
+}
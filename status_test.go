@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatusWhileRunStarts(t *testing.T) {
+	fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true"))
+	sc := newTestScheduler(t, fd)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- sc.Run(ctx) }()
+
+	deadline := time.After(5 * time.Second)
+	for len(sc.Status()) == 0 {
+		select {
+		case err := <-done:
+			t.Fatalf("run finished before jobs are scheduled: %v", err)
+		case <-deadline:
+			t.Fatal("jobs are not scheduled")
+		default:
+		}
+	}
+	if status := sc.Status(); len(status) != 1 || status[0].Service != "web" || status[0].Next.IsZero() {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
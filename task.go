@@ -0,0 +1,478 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Mode defines how job is executed.
+type Mode string
+
+const (
+	ModeRun     Mode = "run"     // start service container and wait till it stops
+	ModeExec    Mode = "exec"    // execute command inside running service container
+	ModeCreate  Mode = "create"  // create throwaway container from service container config, like compose run --rm
+	ModeRestart Mode = "restart" // restart service container
+	ModeSignal  Mode = "signal"  // send signal to service container
+)
+
+const defaultRetryInterval = 10 * time.Second
+
+// Replicas policy of scaled services.
+const (
+	replicasAll = "all" // run job on every replica
+	replicasOne = "one" // run job on single replica with the lowest number
+)
+
+// Concurrency defines what to do when job is triggered while previous run is still in progress.
+type Concurrency string
+
+const (
+	ConcurrencyForbid Concurrency = "forbid" // skip new run
+	ConcurrencyAllow  Concurrency = "allow"  // run concurrently
+	ConcurrencyQueue  Concurrency = "queue"  // run after current finished, at most one pending run
+)
+
+// ParseErrorAction defines what to do with job which can not be parsed or validated during discovery.
+type ParseErrorAction string
+
+const (
+	ParseErrorFail ParseErrorAction = "fail" // fail discovery, so scheduler doesn't start with invalid configuration
+	ParseErrorSkip ParseErrorAction = "skip" // log and skip invalid job, other jobs are scheduled
+)
+
+// NotifyOn defines which job runs are reported to notification targets.
+type NotifyOn string
+
+const (
+	NotifyAlways  NotifyOn = "always"  // every run
+	NotifyFailure NotifyOn = "failure" // failed runs only
+	NotifyChange  NotifyOn = "change"  // runs with result different from previous run of the job
+)
+
+type Task struct {
+	Project     string
+	Name        string
+	Service     string
+	Container   string
+	Schedule    string
+	Mode        Mode
+	Command     []string
+	Timeout     time.Duration // zero means no timeout
+	retries     int           // additional attempts after failed execution
+	retryDelay  time.Duration // delay between attempts
+	Concurrency Concurrency
+	Logging     bool // copy output to scheduler logs and notifications
+	artifacts   bool
+	passStatus  bool
+	expect      *regexp.Regexp // pattern which output must contain for success, not checked if nil
+	outputFile  string         // path in container which command output is redirected to
+	minUptime   time.Duration
+	healthy     bool // run only if container is healthy
+	leaderOnly  bool
+	replicas    string // replicas policy of scaled service
+	replica     int    // container number of scaled service
+	catchup     bool   // run at startup if scheduled run was missed while scheduler was offline
+	onStart     bool   // run at startup in addition to schedule
+	stopOnStart bool   // stop service container at scheduler start, so it runs only by schedule
+	skipRunning bool   // skip run if service container is already running, ex. started by another scheduler
+	env         []string
+	success     []int // exit codes treated as success, only 0 if empty
+	maxLogBytes int   // max output copied to scheduler logs, zero means scheduler default
+	user        string
+	privileged  bool // exec command with extended privileges
+	tty         bool // exec command with pseudo-terminal
+	shell       bool // command is executed by sh -c
+	workdir     string
+	stdin       string        // literal input of exec command or @path to file with input
+	signal      string        // signal name for signal mode, ex: SIGHUP
+	notifyOn    NotifyOn      // empty means scheduler default
+	notifyURL   string        // HTTP notification target of the job, global targets if empty
+	notifyAuth  string        // Authorization header of notifyURL target
+	jitter      time.Duration // max random delay before scheduled run, zero means scheduler default
+	warmup      time.Duration // no runs within this duration after scheduler start
+	instance    string        // container name, stable across container re-creation
+}
+
+// key identifies the job across re-discoveries.
+func (t Task) key() string {
+	return t.Service + "/" + t.Name + "/" + t.instance
+}
+
+// sortTasks orders tasks by project, service, job name and container ID, so discovery result doesn't depend on order of
+// containers returned by Docker.
+func sortTasks(tasks []Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Project != tasks[j].Project {
+			return tasks[i].Project < tasks[j].Project
+		}
+		if tasks[i].Service != tasks[j].Service {
+			return tasks[i].Service < tasks[j].Service
+		}
+		if tasks[i].Name != tasks[j].Name {
+			return tasks[i].Name < tasks[j].Name
+		}
+		return tasks[i].Container < tasks[j].Container
+	})
+}
+
+// defaultMode is used when mode is not set explicitly.
+func defaultMode(command []string) Mode {
+	if len(command) == 0 {
+		return ModeRun
+	}
+	return ModeExec
+}
+
+func parseTask(c containerSummary, service string, jl jobLabels) (Task, error) {
+	var args []string
+	shell := jl.bool(shellKey)
+	if v := jl.get(execKey); v != "" {
+		if shell {
+			args = []string{"sh", "-c", v}
+		} else {
+			cmd, err := shellquote.Split(v)
+			if err != nil {
+				return Task{}, fmt.Errorf("parse command: %w", err)
+			}
+			args = cmd
+		}
+	}
+	if path := jl.get(execFileKey); path != "" {
+		if args != nil {
+			return Task{}, fmt.Errorf("%s and %s are mutually exclusive", execKey, execFileKey)
+		}
+		args = []string{"sh", path}
+	}
+	outputFile := jl.get(outputFileKey)
+	if outputFile != "" && args != nil {
+		args = redirectOutput(args, outputFile)
+	}
+
+	timeout, err := jl.duration(timeoutKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	minUptime, err := jl.duration(minUptimeKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	jitter, err := jl.duration(jitterKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	warmup, err := jl.duration(initDelayKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	schedule := jl.get(cronKey)
+	if zone := jl.get(timezoneKey); zone != "" {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return Task{}, fmt.Errorf("load timezone: %w", err)
+		}
+		schedule = withTimezone(schedule, zone)
+	}
+
+	env, err := parseEnv(jl.get(envKey))
+	if err != nil {
+		return Task{}, fmt.Errorf("parse env: %w", err)
+	}
+
+	concurrency := Concurrency(jl.get(concurrencyKey))
+	if concurrency == "" {
+		concurrency = ConcurrencyForbid
+	}
+
+	success, err := parseCodes(jl.get(successKey))
+	if err != nil {
+		return Task{}, fmt.Errorf("parse %s: %w", successKey, err)
+	}
+
+	expect, err := parseExpect(jl.get(expectKey))
+	if err != nil {
+		return Task{}, fmt.Errorf("parse %s: %w", expectKey, err)
+	}
+
+	var maxLogBytes int
+	if v := jl.get(maxLogKey); v != "" {
+		maxLogBytes, err = strconv.Atoi(v)
+		if err != nil {
+			return Task{}, fmt.Errorf("parse %s: %w", maxLogKey, err)
+		}
+	}
+
+	var retries int
+	if v := jl.get(retriesKey); v != "" {
+		retries, err = strconv.Atoi(v)
+		if err != nil || retries < 0 {
+			return Task{}, fmt.Errorf("parse %s: invalid number of retries %q", retriesKey, v)
+		}
+	}
+
+	retryDelay, err := jl.duration(retryIntKey)
+	if err != nil {
+		return Task{}, err
+	}
+	if retryDelay == 0 {
+		retryDelay = defaultRetryInterval
+	}
+
+	privileged, err := jl.strictBool(privilegedKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	tty, err := jl.strictBool(ttyKey)
+	if err != nil {
+		return Task{}, err
+	}
+
+	replicas := jl.get(replicasKey)
+	if replicas == "" {
+		replicas = replicasAll
+	}
+
+	mode := Mode(jl.get(modeKey))
+	if mode == "" {
+		mode = defaultMode(args)
+	}
+
+	task := Task{
+		Name:        jl.name,
+		Mode:        mode,
+		Timeout:     timeout,
+		retries:     retries,
+		retryDelay:  retryDelay,
+		Concurrency: concurrency,
+		Container:   c.ID,
+		instance:    c.Name,
+		Schedule:    schedule,
+		Service:     service,
+		Command:     args,
+		Logging:     jl.bool(logsKey),
+		success:     success,
+		expect:      expect,
+		outputFile:  outputFile,
+		maxLogBytes: maxLogBytes,
+		artifacts:   jl.bool(artifactsKey),
+		passStatus:  jl.bool(prevStatusKey),
+		minUptime:   minUptime,
+		healthy:     jl.bool(healthyKey),
+		leaderOnly:  jl.bool(leaderOnlyKey),
+		replicas:    replicas,
+		replica:     replicaNumber(c.Labels),
+		catchup:     jl.bool(catchupKey),
+		onStart:     jl.bool(onStartKey),
+		stopOnStart: jl.bool(stopOnStartKey),
+		skipRunning: jl.bool(skipRunKey),
+		env:         env,
+		user:        jl.get(userKey),
+		privileged:  privileged,
+		tty:         tty,
+		shell:       shell,
+		workdir:     jl.get(workdirKey),
+		stdin:       jl.get(stdinKey),
+		signal:      normalizeSignal(jl.get(signalKey)),
+		notifyOn:    NotifyOn(jl.get(notifyKey)),
+		notifyURL:   jl.get(notifyURLKey),
+		notifyAuth:  jl.get(notifyAuthKey),
+		jitter:      jitter,
+		warmup:      warmup,
+	}
+	return task, task.validate()
+}
+
+// validate detects contradicting settings instead of silently changing job behaviour.
+func (t *Task) validate() error {
+	switch t.Concurrency {
+	case ConcurrencyForbid, ConcurrencyAllow, ConcurrencyQueue:
+	default:
+		return fmt.Errorf("unknown concurrency policy %q", t.Concurrency)
+	}
+	switch t.notifyOn {
+	case "", NotifyAlways, NotifyFailure, NotifyChange:
+	default:
+		return fmt.Errorf("unknown notify policy %q", t.notifyOn)
+	}
+	switch t.replicas {
+	case "", replicasAll, replicasOne:
+	default:
+		return fmt.Errorf("unknown replicas policy %q", t.replicas)
+	}
+	if t.Mode == ModeSignal {
+		if t.signal == "" {
+			return errors.New("signal mode requires signal")
+		}
+		if !knownSignals[t.signal] {
+			return fmt.Errorf("unknown signal %q", t.signal)
+		}
+	} else if t.signal != "" {
+		return errors.New("signal is supported only in signal mode")
+	}
+	if t.stopOnStart && t.Mode != ModeRun {
+		return errors.New("stop-on-start is supported only in run mode")
+	}
+	if t.outputFile != "" && t.expect != nil {
+		return errors.New("expect can't be checked if output is redirected to output-file")
+	}
+	if t.skipRunning && t.Mode != ModeRun {
+		return errors.New("skip-running is supported only in run mode")
+	}
+	switch t.Mode {
+	case ModeExec:
+		if len(t.Command) == 0 {
+			return errors.New("exec mode requires command")
+		}
+	case ModeRun, ModeCreate, ModeRestart, ModeSignal:
+		if len(t.Command) > 0 {
+			return fmt.Errorf("command is not supported in %s mode", t.Mode)
+		}
+		if t.artifacts {
+			return errors.New("artifacts are supported only in exec mode")
+		}
+		if t.passStatus {
+			return errors.New("prev-status is supported only in exec mode")
+		}
+		if t.minUptime > 0 {
+			return errors.New("min-uptime is supported only in exec mode")
+		}
+		if t.healthy {
+			return errors.New("require-healthy is supported only in exec mode")
+		}
+		if len(t.env) > 0 {
+			return errors.New("env is supported only in exec mode")
+		}
+		if t.user != "" {
+			return errors.New("user is supported only in exec mode")
+		}
+		if t.privileged {
+			return errors.New("privileged is supported only in exec mode")
+		}
+		if t.tty {
+			return errors.New("tty is supported only in exec mode")
+		}
+		if t.expect != nil {
+			return errors.New("expect is supported only in exec mode")
+		}
+		if t.outputFile != "" {
+			return errors.New("output-file is supported only in exec mode")
+		}
+		if t.workdir != "" {
+			return errors.New("workdir is supported only in exec mode")
+		}
+		if t.stdin != "" {
+			return errors.New("stdin is supported only in exec mode")
+		}
+		if t.shell {
+			return errors.New("shell is supported only in exec mode")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", t.Mode)
+	}
+	return nil
+}
+
+//nolint:gochecknoglobals
+var knownSignals = map[string]bool{
+	"SIGHUP": true, "SIGINT": true, "SIGQUIT": true, "SIGKILL": true, "SIGUSR1": true, "SIGUSR2": true,
+	"SIGTERM": true, "SIGALRM": true, "SIGCONT": true, "SIGSTOP": true, "SIGTSTP": true, "SIGWINCH": true,
+}
+
+// normalizeSignal converts signal name to upper case with SIG prefix: hup -> SIGHUP.
+func normalizeSignal(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" || strings.HasPrefix(name, "SIG") {
+		return name
+	}
+	return "SIG" + name
+}
+
+// runsOnStart checks whether job runs once when scheduler starts: by @startup schedule or on-start label.
+func (t Task) runsOnStart() bool {
+	return t.onStart || t.Schedule == startupDescriptor
+}
+
+// succeeded checks whether exit code of command or container means success.
+func (t Task) succeeded(code int) bool {
+	if len(t.success) == 0 {
+		return code == 0
+	}
+	for _, c := range t.success {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCodes parses comma-separated list of exit codes.
+func parseCodes(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var ans []int
+	for _, item := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		if code < 0 || code > 255 {
+			return nil, fmt.Errorf("exit code %d is out of range 0-255", code)
+		}
+		ans = append(ans, code)
+	}
+	return ans, nil
+}
+
+// parseExpect parses expected output: regular expression enclosed in slashes (ex: /status: (ok|ready)/) or plain
+// substring otherwise.
+func parseExpect(value string) (*regexp.Regexp, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if len(value) > 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		return regexp.Compile(value[1 : len(value)-1])
+	}
+	return regexp.Compile(regexp.QuoteMeta(value))
+}
+
+// redirectOutput wraps command to write its stdout and stderr to file in container, replacing previous content.
+// Command is passed to sh as positional arguments, so only the path needs quoting.
+func redirectOutput(args []string, path string) []string {
+	script := `exec "$0" "$@" > ` + shellquote.Join(path) + ` 2>&1`
+	return append([]string{"sh", "-c", script}, args...)
+}
+
+// replicaNumber of container set by compose for scaled services, zero if not set.
+func replicaNumber(labels map[string]string) int {
+	n, _ := strconv.Atoi(labels[composeNumberLabel])
+	return n
+}
+
+// input of exec command: literal value of stdin label or content of file if value starts with @.
+func (t Task) input() ([]byte, error) {
+	if path, ok := strings.CutPrefix(t.stdin, "@"); ok {
+		return os.ReadFile(path)
+	}
+	return []byte(t.stdin), nil
+}
+
+// withTimezone prefixes schedule by CRON_TZ unless zone is empty, schedule is @startup or already has explicit zone.
+func withTimezone(schedule, zone string) string {
+	if zone == "" || schedule == startupDescriptor || strings.HasPrefix(schedule, "CRON_TZ=") || strings.HasPrefix(schedule, "TZ=") {
+		return schedule
+	}
+	return "CRON_TZ=" + zone + " " + schedule
+}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSortTasksShuffled(t *testing.T) {
+	var expected = []Task{
+		{Project: "a", Service: "db", Name: "backup", Container: "c1"},
+		{Project: "a", Service: "db", Name: "backup", Container: "c2"},
+		{Project: "a", Service: "db", Name: "vacuum", Container: "c1"},
+		{Project: "a", Service: "web", Name: "default", Container: "c3"},
+		{Project: "b", Service: "db", Name: "backup", Container: "c0"},
+		{Project: "b", Service: "web", Name: "default", Container: "c4"},
+	}
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	for i := 0; i < 20; i++ {
+		tasks := append([]Task(nil), expected...)
+		rnd.Shuffle(len(tasks), func(i, j int) { tasks[i], tasks[j] = tasks[j], tasks[i] })
+		sortTasks(tasks)
+		if !reflect.DeepEqual(tasks, expected) {
+			t.Fatalf("unexpected order: %+v", tasks)
+		}
+	}
+}
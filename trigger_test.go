@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTriggerRequiresProjectForAmbiguousService(t *testing.T) {
+	other := testContainer("c2", "db", "cron=@daily", "exec=true")
+	other.Labels[composeProjectLabel] = "other"
+	fd := newFakeDocker(t, testContainer("c1", "db", "cron=@daily", "exec=true"), other)
+	sc := newTestScheduler(t, fd, WithProject("other"))
+
+	err := sc.Trigger(context.Background(), "", "db", "", false)
+	if !errors.Is(err, errAmbiguousService) {
+		t.Fatalf("expected ambiguous service error, got %v", err)
+	}
+
+	if err := sc.Trigger(context.Background(), "other", "db", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if e := fd.lastExec(); e == nil || e.Container != "c2" {
+		t.Fatalf("expected exec in container of project other, got %+v", e)
+	}
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (fc fixedClock) Now() time.Time {
+	return time.Time(fc)
+}
+
+func TestMinUptimeUsesSchedulerClock(t *testing.T) {
+	started := time.Date(2023, 1, 20, 11, 10, 39, 0, time.UTC) // start time reported by fake daemon
+	for _, c := range []struct {
+		uptime   time.Duration
+		executed bool
+	}{
+		{30 * time.Second, false},
+		{2 * time.Minute, true},
+	} {
+		fd := newFakeDocker(t, testContainer("c1", "web", "cron=@daily", "exec=true", "min-uptime=1m"))
+		sc := newTestScheduler(t, fd, WithClock(fixedClock(started.Add(c.uptime))))
+		if err := sc.Trigger(context.Background(), "", "web", "", false); err != nil {
+			t.Fatal(err)
+		}
+		if executed := fd.lastExec() != nil; executed != c.executed {
+			t.Fatalf("uptime %v: expected executed=%v, got %v", c.uptime, c.executed, executed)
+		}
+	}
+}
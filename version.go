@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BuildInfo describes build of the scheduler binary, reported by /version endpoint.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"built_by"`
+}
+
+// versionStatus is response of /version: build of the scheduler and its effective configuration.
+type versionStatus struct {
+	BuildInfo
+	Project string `json:"project"` // resolved projects, comma-separated
+	Jobs    int    `json:"jobs"`    // number of scheduled jobs
+}
+
+// serveVersion handles GET /version.
+func (sc *Scheduler) serveVersion(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sc.lock.Lock()
+	jobs := len(sc.jobs)
+	sc.lock.Unlock()
+	writeJSON(writer, http.StatusOK, versionStatus{
+		BuildInfo: sc.build,
+		Project:   strings.Join(sc.projects, ","),
+		Jobs:      jobs,
+	})
+}
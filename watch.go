@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const watchRetryInterval = 5 * time.Second
+
+// schedule synchronizes cron engine with discovered tasks. Jobs with unchanged schedule keep their runtime state
+// (including running guard) and only get updated task definition. Nothing is changed if any schedule is invalid.
+func (sc *Scheduler) schedule(ctx context.Context, tasks []Task) error {
+	var schedules = make([]cron.Schedule, 0, len(tasks))
+	for _, t := range tasks {
+		schedule, err := sc.parseSchedule(t)
+		if err != nil {
+			return fmt.Errorf("add job %s in service %s: %w", t.Name, t.Service, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	var added, removed, kept int
+	var actual = make(map[string]bool, len(tasks))
+	for i, t := range tasks {
+		key := t.key()
+		actual[key] = true
+		if old, ok := sc.jobs[key]; ok {
+			if old.current().Schedule == t.Schedule {
+				old.update(t)
+				kept++
+				continue
+			}
+			sc.engine.Remove(old.entry)
+			removed++
+		}
+		logger := sc.logger.With("service", t.Service, "job", t.Name)
+		logger.Info("job scheduled", "schedule", t.Schedule, "next", nextFire(schedules[i], sc.clock.Now()),
+			"mode", t.Mode, "logging", t.Logging, "artifacts", t.artifacts)
+		if len(t.env) > 0 {
+			logger.Debug("job environment", "env", maskEnv(t.env))
+		}
+		if spread, ok := schedules[i].(spreadSchedule); ok {
+			logger.Info("job spread", "offset", spread.offset)
+		}
+		j := newJob(t)
+		j.sched = schedules[i]
+		j.entry = sc.engine.Schedule(schedules[i], cron.FuncJob(func() {
+			if sc.paused.Load() {
+				t := j.current()
+				sc.logger.Debug("scheduled run skipped, scheduling is paused", "service", t.Service, "job", t.Name)
+				return
+			}
+			if left := sc.warmupLeft(j.current()); left > 0 {
+				t := j.current()
+				sc.logger.Info("scheduled run skipped, initial delay after scheduler start", "service", t.Service, "job", t.Name,
+					"result", resultSkipped, "left", left.Seconds())
+				return
+			}
+			r := &run{id: newRunID()}
+			if err := sc.delayRun(ctx, j, r); err != nil {
+				return
+			}
+			_ = sc.runJob(ctx, j, r)
+		}))
+		sc.jobs[key] = j
+		added++
+	}
+	for key, j := range sc.jobs {
+		if actual[key] {
+			continue
+		}
+		t := j.current()
+		sc.logger.Info("job removed", "service", t.Service, "job", t.Name)
+		sc.engine.Remove(j.entry)
+		delete(sc.jobs, key)
+		removed++
+	}
+	sc.logger.Info("jobs scheduled", "added", added, "removed", removed, "kept", kept)
+	return nil
+}
+
+// reload discovers tasks again and reschedules them.
+func (sc *Scheduler) reload(ctx context.Context) error {
+	tasks, err := sc.listTasks(ctx)
+	if err != nil {
+		sc.health.discovered(0, err)
+		return fmt.Errorf("list tasks: %w", err)
+	}
+	err = sc.schedule(ctx, tasks)
+	sc.health.discovered(len(tasks), err)
+	return err
+}
+
+// watchEvents reloads tasks when labeled containers of the project are started or destroyed.
+func (sc *Scheduler) watchEvents(ctx context.Context) {
+	for {
+		messages, errs := sc.docker.events(ctx, sc.projectLabel)
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				sc.logger.Error("docker events stream failed", "error", err)
+				break stream
+			case event := <-messages:
+				if !slices.Contains(sc.projects, event.Labels[sc.projectLabel]) || !hasSchedulerLabels(event.Labels) {
+					continue
+				}
+				sc.logger.Info("container changed, reloading jobs", "container", event.Name, "service", event.Labels[sc.serviceLabel], "action", event.Action)
+				if err := sc.reload(ctx); err != nil {
+					sc.logger.Error("reload jobs failed", "error", err)
+				}
+			}
+		}
+		select {
+		case <-time.After(watchRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextFire returns next fire time of the schedule for logs: time or "never" for schedules which don't fire (@startup).
+func nextFire(schedule cron.Schedule, now time.Time) string {
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format(time.RFC3339)
+}
+
+func hasSchedulerLabels(labels map[string]string) bool {
+	for key := range labels {
+		if strings.HasPrefix(key, labelPrefix) {
+			return true
+		}
+	}
+	return false
+}